@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// list prints the approvals of the namespace, or only the ones the user is an approver of if --mine is given
+func (p *plugin) list() error {
+	approvals := &tmaxv1.ApprovalList{}
+	if err := p.client.List(context.TODO(), approvals, client.InNamespace(p.namespace)); err != nil {
+		return err
+	}
+
+	sort.Slice(approvals.Items, func(i, j int) bool {
+		return approvals.Items[j].CreationTimestamp.Before(&approvals.Items[i].CreationTimestamp)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tSTATUS\tTALLY\tMY DECISION\tAGE")
+	for i := range approvals.Items {
+		a := &approvals.Items[i]
+		if _, listed := a.ActivePolicy().Users[p.user]; p.opts.mine && !listed {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", a.Namespace, a.Name, phase(a), tally(a), p.decisionOf(a), age(a.CreationTimestamp))
+	}
+	return w.Flush()
+}
+
+// describe prints the policy, the decisions and the history of the approval
+func (p *plugin) describe(name string) error {
+	a := &tmaxv1.Approval{}
+	if err := p.client.Get(context.TODO(), types.NamespacedName{Namespace: p.namespace, Name: name}, a); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", a.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", a.Namespace)
+	fmt.Fprintf(w, "Status:\t%s\n", phase(a))
+	fmt.Fprintf(w, "Tally:\t%s\n", tally(a))
+	if i := int(a.Status.CurrentStage); i >= 0 && i < len(a.Spec.Stages) {
+		fmt.Fprintf(w, "Stage:\t%s (%d/%d)\n", a.Spec.Stages[i].Name, i+1, len(a.Spec.Stages))
+	}
+	if expiry := a.ExpiryTime(); expiry != nil {
+		fmt.Fprintf(w, "Expires:\t%s\n", expiry.Format(time.RFC3339))
+	}
+
+	policy := a.ActivePolicy()
+	fmt.Fprintf(w, "Threshold:\t%d\n", policy.Threshold)
+	fmt.Fprintf(w, "Users:\t%s\n", strings.Join(sortedKeys(policy.Users), ", "))
+	if len(policy.Groups) > 0 {
+		var groups []string
+		for _, g := range policy.Groups {
+			groups = append(groups, fmt.Sprintf("%s(%d)", g.Name, g.Threshold))
+		}
+		fmt.Fprintf(w, "Groups:\t%s\n", strings.Join(groups, ", "))
+	}
+
+	fmt.Fprintln(w, "Conditions:")
+	fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+	for _, cond := range a.Status.Conditions {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", cond.Type, cond.Status, cond.Reason, cond.Message)
+	}
+
+	fmt.Fprintln(w, "Approvers:")
+	fmt.Fprintln(w, "  USER\tDECISION\tTIME\tREASON\tCOMMENT")
+	for _, approver := range a.Status.Approvers {
+		user := approver.UserID
+		if approver.OnBehalfOf != "" {
+			user = fmt.Sprintf("%s on behalf of %s", approver.UserID, approver.OnBehalfOf)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", user, approver.Decision, approver.ApprovedTime.Format(time.RFC3339), approver.Reason, approver.Comment)
+	}
+
+	if len(a.Status.Escalations) > 0 {
+		fmt.Fprintln(w, "Escalations:")
+		for _, e := range a.Status.Escalations {
+			fmt.Fprintf(w, "  %s\t%d\t%s\n", e.Time.Format(time.RFC3339), e.Level, e.Message)
+		}
+	}
+
+	if len(a.Status.History) > 0 {
+		fmt.Fprintln(w, "History:")
+		for _, e := range a.Status.History {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.UserID, e.Action, e.Decision)
+		}
+	}
+	return w.Flush()
+}
+
+// decide sets the decision of the user to the status of the approval.
+// The validating webhook of the operator checks if the user can make the decision
+func (p *plugin) decide(name string, decision tmaxv1.DecisionType) error {
+	key := types.NamespacedName{Namespace: p.namespace, Name: name}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		a := &tmaxv1.Approval{}
+		if err := p.client.Get(context.TODO(), key, a); err != nil {
+			return err
+		}
+		if a.Status.IsFinal() {
+			return fmt.Errorf("approval %s/%s already ended: %s", a.Namespace, a.Name, phase(a))
+		}
+
+		// Only status.approvers is patched. The resource version is kept in the patch, so that the decisions of
+		// others made in the meantime are not overwritten
+		orig := a.DeepCopy()
+		orig.ResourceVersion = ""
+
+		a.Status.SetDecision(tmaxv1.Approver{
+			UserID:       p.user,
+			Decision:     decision,
+			ApprovedTime: metav1.NewTime(time.Now()),
+			Group:        p.opts.group,
+			Reason:       p.opts.reason,
+			Comment:      p.opts.comment,
+			OnBehalfOf:   p.opts.onBehalfOf,
+		})
+		return p.client.Status().Patch(context.TODO(), a, client.MergeFrom(orig))
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("approval.tmax.io/%s %s by %s\n", name, strings.ToLower(string(decision)), p.user)
+	return nil
+}
+
+// watch prints the changes of the approvals, or the approval of the name if given, until interrupted
+func (p *plugin) watch(name string) error {
+	opts := metav1.ListOptions{}
+	if name != "" {
+		opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+	}
+
+	resource := p.dynamic.Resource(tmaxv1.SchemeGroupVersion.WithResource("approvals")).Namespace(p.namespace)
+	watcher, err := resource.Watch(opts)
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "EVENT\tNAMESPACE\tNAME\tSTATUS\tTALLY\tMY DECISION")
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for event := range watcher.ResultChan() {
+		u, ok := event.Object.(*unstructured.Unstructured)
+		if !ok {
+			return fmt.Errorf("unexpected watch event %s: %v", event.Type, event.Object)
+		}
+		a := &tmaxv1.Approval{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), a); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", event.Type, a.Namespace, a.Name, phase(a), tally(a), p.decisionOf(a))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decisionOf returns the decision counted for the user, or - if the user has not decided
+func (p *plugin) decisionOf(a *tmaxv1.Approval) string {
+	approver := a.Status.GetEffectiveApprover(p.user)
+	if approver == nil {
+		approver = a.Status.GetApprover(p.user)
+	}
+	if approver == nil {
+		return "-"
+	}
+	return string(approver.Decision)
+}
+
+// phase returns the condition the approval ended with, or Waiting if it is in progress
+func phase(a *tmaxv1.Approval) string {
+	for _, cond := range a.Status.Conditions {
+		if cond.Type != tmaxv1.ConditionWaiting && cond.IsTrue() {
+			return string(cond.Type)
+		}
+	}
+	if a.Status.GetCondition(tmaxv1.ConditionWaiting) != nil {
+		return string(tmaxv1.ConditionWaiting)
+	}
+	return "-"
+}
+
+func tally(a *tmaxv1.Approval) string {
+	if a.Status.Tally != nil {
+		return a.Status.Tally.Summary
+	}
+	return a.ActivePolicy().Tally(a.Status.Approvers).Summary
+}
+
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+const usage = `kubectl approval lists and decides approvals as the user of the current kubeconfig.
+
+Usage:
+  kubectl approval list [--mine] [-A]
+  kubectl approval describe NAME
+  kubectl approval approve NAME [--comment COMMENT] [--reason REASON] [--group GROUP] [--on-behalf-of USER]
+  kubectl approval reject NAME [--reason REASON] [--comment COMMENT] [--group GROUP] [--on-behalf-of USER]
+  kubectl approval watch [NAME | -A]
+
+Flags:
+`
+
+// options are the flags given to the plugin
+type options struct {
+	kubeconfig    string
+	context       string
+	namespace     string
+	as            string
+	allNamespaces bool
+
+	mine       bool
+	comment    string
+	reason     string
+	group      string
+	onBehalfOf string
+}
+
+// plugin is the clients and the identity the commands run with
+type plugin struct {
+	opts      *options
+	user      string
+	namespace string
+	client    client.Client
+	dynamic   dynamic.Interface
+}
+
+func main() {
+	opts := &options{}
+	flags := pflag.NewFlagSet("kubectl-approval", pflag.ContinueOnError)
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file")
+	flags.StringVar(&opts.context, "context", "", "The name of the kubeconfig context to use")
+	flags.StringVarP(&opts.namespace, "namespace", "n", "", "The namespace of the approvals")
+	flags.StringVar(&opts.as, "as", "", "Username to impersonate, deciding as the user")
+	flags.BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "List or watch the approvals across all namespaces")
+	flags.BoolVar(&opts.mine, "mine", false, "List only the approvals the user is an approver of")
+	flags.StringVar(&opts.comment, "comment", "", "Comment on the decision")
+	flags.StringVar(&opts.reason, "reason", "", "Short reason of the decision, e.g., SecurityConcern")
+	flags.StringVar(&opts.group, "group", "", "Group through which the decision is counted, if the user is not listed as an approver")
+	flags.StringVar(&opts.onBehalfOf, "on-behalf-of", "", "User who delegated the decision to the user")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == pflag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	p, err := newPlugin(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if err := p.run(args[0], args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", explain(err).Error())
+		os.Exit(1)
+	}
+}
+
+func (p *plugin) run(command string, args []string) error {
+	name := func() (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s requires the name of an approval", command)
+		}
+		if p.opts.allNamespaces {
+			return "", fmt.Errorf("%s of an approval cannot be used with --all-namespaces, use --namespace instead", command)
+		}
+		return args[0], nil
+	}
+
+	switch command {
+	case "list":
+		return p.list()
+	case "describe":
+		n, err := name()
+		if err != nil {
+			return err
+		}
+		return p.describe(n)
+	case "approve", "reject":
+		n, err := name()
+		if err != nil {
+			return err
+		}
+		decision := tmaxv1.DecisionApproved
+		if command == "reject" {
+			decision = tmaxv1.DecisionRejected
+		}
+		return p.decide(n, decision)
+	case "watch":
+		if len(args) > 1 {
+			return fmt.Errorf("watch accepts at most one approval name")
+		}
+		n := ""
+		if len(args) == 1 {
+			var err error
+			if n, err = name(); err != nil {
+				return err
+			}
+		}
+		return p.watch(n)
+	default:
+		return fmt.Errorf("unknown command %q, one of list, describe, approve, reject or watch is expected", command)
+	}
+}
+
+func newPlugin(opts *options) (*plugin, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = opts.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.context}
+	overrides.Context.Namespace = opts.namespace
+	overrides.AuthInfo.Impersonate = opts.as
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, err
+	}
+	if opts.allNamespaces {
+		namespace = ""
+	}
+
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		return nil, err
+	}
+	if err := tmaxv1.SchemeBuilder.AddToScheme(s); err != nil {
+		return nil, err
+	}
+	c, err := client.New(config, client.Options{Scheme: s})
+	if err != nil {
+		return nil, err
+	}
+	d, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	user, err := identity(d, config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin{opts: opts, user: user, namespace: namespace, client: c, dynamic: d}, nil
+}
+
+// selfSubjectReviews are the versions of SelfSubjectReview to ask the api server who the user is, in order
+var selfSubjectReviews = []schema.GroupVersionResource{
+	{Group: "authentication.k8s.io", Version: "v1", Resource: "selfsubjectreviews"},
+	{Group: "authentication.k8s.io", Version: "v1beta1", Resource: "selfsubjectreviews"},
+	{Group: "authentication.k8s.io", Version: "v1alpha1", Resource: "selfsubjectreviews"},
+}
+
+// identity returns the username the api server authenticates the kubeconfig as.
+// It is the impersonated user if given, or the user the api server reviews the credentials as.
+// If the api server does not serve SelfSubjectReview, the common name of the client certificate or the basic auth
+// username is used. Other credentials, e.g., tokens, OIDC or exec plugins, cannot be resolved locally
+func identity(d dynamic.Interface, config *rest.Config, opts *options) (string, error) {
+	if opts.as != "" {
+		return opts.as, nil
+	}
+
+	for _, gvr := range selfSubjectReviews {
+		review := &unstructured.Unstructured{}
+		review.SetAPIVersion(gvr.GroupVersion().String())
+		review.SetKind("SelfSubjectReview")
+		result, err := d.Resource(gvr).Create(review, metav1.CreateOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot review the user of the kubeconfig, use --as to specify it: %s", err.Error())
+		}
+		user, _, err := unstructured.NestedString(result.Object, "status", "userInfo", "username")
+		if err != nil || user == "" {
+			return "", errors.New("api server did not return the user of the kubeconfig, use --as to specify it")
+		}
+		return user, nil
+	}
+
+	certData := config.CertData
+	if len(certData) == 0 && config.CertFile != "" {
+		data, err := ioutil.ReadFile(config.CertFile)
+		if err != nil {
+			return "", err
+		}
+		certData = data
+	}
+	if len(certData) > 0 {
+		block, _ := pem.Decode(certData)
+		if block == nil {
+			return "", errors.New("cannot decode the client certificate")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", err
+		}
+		return cert.Subject.CommonName, nil
+	}
+
+	if config.Username != "" {
+		return config.Username, nil
+	}
+	return "", errors.New("cannot determine the user of the kubeconfig, use --as to specify it")
+}
+
+// explain strips the details of the errors from the api server, so that the reason the request is denied stands out
+func explain(err error) error {
+	status, ok := err.(k8serrors.APIStatus)
+	if !ok {
+		return err
+	}
+	msg := status.Status().Message
+	const denied = "denied the request: "
+	if i := strings.Index(msg, denied); i >= 0 {
+		return fmt.Errorf("decision is denied by the approval webhook: %s", msg[i+len(denied):])
+	}
+	if k8serrors.IsForbidden(err) {
+		return fmt.Errorf("not permitted: %s", msg)
+	}
+	return err
+}
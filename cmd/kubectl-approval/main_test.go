@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func TestIdentity(t *testing.T) {
+	review := `{"apiVersion":"authentication.k8s.io/%s","kind":"SelfSubjectReview","status":{"userInfo":{"username":"oidc:alice"}}}`
+
+	tc := map[string]struct {
+		// served are the versions of SelfSubjectReview served, replying with the code
+		served   map[string]int
+		config   rest.Config
+		as       string
+		expected string
+	}{
+		"impersonated":   {as: "bob", expected: "bob"},
+		"v1":             {served: map[string]int{"v1": http.StatusCreated}, config: rest.Config{BearerToken: "token"}, expected: "oidc:alice"},
+		"v1beta1":        {served: map[string]int{"v1beta1": http.StatusCreated}, config: rest.Config{BearerToken: "token"}, expected: "oidc:alice"},
+		"forbidden":      {served: map[string]int{"v1": http.StatusForbidden}, config: rest.Config{BearerToken: "token"}},
+		"notServedToken": {config: rest.Config{BearerToken: "token"}},
+		"notServedBasic": {config: rest.Config{Username: "carol", Password: "password"}, expected: "carol"},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			requested := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = true
+				for version, code := range c.served {
+					if r.Method == "POST" && r.URL.Path == "/apis/authentication.k8s.io/"+version+"/selfsubjectreviews" {
+						w.Header().Set("Content-Type", "application/json")
+						w.WriteHeader(code)
+						if code == http.StatusCreated {
+							_, _ = w.Write([]byte(strings.Replace(review, "%s", version, 1)))
+						}
+						return
+					}
+				}
+				http.NotFound(w, r)
+			}))
+			defer server.Close()
+
+			config := c.config
+			config.Host = server.URL
+			d, err := dynamic.NewForConfig(&config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := identity(d, &config, &options{as: c.as})
+			if c.expected == "" {
+				if err == nil || !strings.Contains(err.Error(), "--as") {
+					t.Fatalf("expected an error suggesting --as, got user %q, error %v", user, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user != c.expected {
+				t.Fatalf("expected %q, got %q", c.expected, user)
+			}
+			if c.as != "" && requested {
+				t.Fatal("expected the impersonated user not to be reviewed")
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmaxv1.SchemeBuilder.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	approval := &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Status: tmaxv1.ApprovalStatus{
+			Conditions: tmaxv1.Conditions{{Type: tmaxv1.ConditionWaiting, Status: corev1.ConditionTrue}},
+			Approvers:  []tmaxv1.Approver{{UserID: "alice", Decision: tmaxv1.DecisionApproved}},
+		},
+	}
+	p := &plugin{
+		opts:      &options{reason: "SecurityConcern"},
+		user:      "bob",
+		namespace: "default",
+		client:    fake.NewFakeClientWithScheme(s, approval),
+	}
+
+	if err := p.decide("test", tmaxv1.DecisionRejected); err != nil {
+		t.Fatal(err)
+	}
+
+	instance := &tmaxv1.Approval{}
+	if err := p.client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "test"}, instance); err != nil {
+		t.Fatal(err)
+	}
+	bob := instance.Status.GetApprover("bob")
+	if len(instance.Status.Approvers) != 2 || bob == nil || bob.Decision != tmaxv1.DecisionRejected || bob.Reason != "SecurityConcern" {
+		t.Fatalf("expected the rejection of bob to be added, got %+v", instance.Status.Approvers)
+	}
+	if instance.Status.GetApprover("alice") == nil || len(instance.Status.Conditions) != 1 {
+		t.Fatalf("expected the other fields to be kept, got %+v", instance.Status)
+	}
+}
+
+func TestRun_AllNamespaces(t *testing.T) {
+	p := &plugin{opts: &options{allNamespaces: true}}
+	for _, command := range []string{"describe", "approve", "reject", "watch"} {
+		if err := p.run(command, []string{"test"}); err == nil || !strings.Contains(err.Error(), "--all-namespaces") {
+			t.Fatalf("expected %s of a name to reject --all-namespaces, got %v", command, err)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	gr := schema.GroupResource{Group: "tmax.io", Resource: "approvals"}
+	denied := k8serrors.NewForbidden(gr, "test",
+		errors.New(`admission webhook "validating.approval.tmax.io" denied the request: user(bob) is not requested for the approval`))
+
+	tc := map[string]struct {
+		err      error
+		expected string
+	}{
+		"denied":    {err: denied, expected: "decision is denied by the approval webhook: user(bob) is not requested for the approval"},
+		"forbidden": {err: k8serrors.NewForbidden(gr, "test", errors.New("no permission")), expected: "not permitted: "},
+		"notFound":  {err: k8serrors.NewNotFound(gr, "test"), expected: `approvals.tmax.io "test" not found`},
+		"other":     {err: errors.New("connection refused"), expected: "connection refused"},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			if got := explain(c.err).Error(); !strings.HasPrefix(got, c.expected) {
+				t.Fatalf("expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"approval-operator/internal"
 	"approval-operator/pkg/apis"
 	"approval-operator/pkg/controller"
+	"approval-operator/pkg/server"
 	approvalWebhook "approval-operator/pkg/webhook/approval"
 	"approval-operator/version"
 
@@ -134,6 +135,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup the api server for tasks
+	if err := mgr.Add(server.New(mgr, server.Addr())); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	// Setup webhooks
 	// Get new client only for updating certificates
 	c, err := internal.Client(client.Options{})
@@ -153,6 +160,7 @@ func main() {
 
 	log.Info("Registering webhooks to the webhook server")
 	webHookServer.Register(approvalWebhook.ValidationPath, &webhook.Admission{Handler: &approvalWebhook.Validator{}})
+	webHookServer.Register(approvalWebhook.DelegationValidationPath, &webhook.Admission{Handler: &approvalWebhook.DelegationValidator{}})
 
 	// Add the Metrics Service
 	addMetrics(ctx, cfg)
@@ -4,12 +4,16 @@ import (
 	"approval-operator/internal"
 	"approval-operator/pkg/apis"
 	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/callback"
 	"bufio"
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"net/http"
 	"os"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -19,9 +23,12 @@ import (
 )
 
 const (
-	ApprovedMessage string = "Approval accepted. Exit the server."
-	RejectedMessage string = "Reject accepted. Exit the server."
-	UnknownMessage  string = "Decision Unknown: "
+	ApprovedMessage       string = "Approval accepted. Exit the server."
+	RejectedMessage       string = "Reject accepted. Exit the server."
+	RequestChangesMessage string = "Change request accepted. Keep waiting."
+	UnknownMessage        string = "Decision Unknown: "
+
+	UnauthorizedMessage string = "Message is not verified: "
 
 	OperatorSvcAddr string = "http://approval-operator.hypercloud4-system.svc.cluster.local:8081/approval"
 
@@ -31,16 +38,62 @@ const (
 	DefaultThreshold int    = 1
 )
 
+// verifier verifies the messages are signed with the callback key given by the operator
+var verifier *callback.Verifier
+
 func messageHandler(w http.ResponseWriter, r *http.Request) {
+	exitCode := 0
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Log.Error(err, "Cannot read the message")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Accept only the messages signed by the operator
+	if err := verifier.Verify(r.Header.Get(callback.SignatureHeader), body); err != nil {
+		log.Log.Info("Rejected unverified message: " + err.Error())
+		w.WriteHeader(http.StatusUnauthorized)
+		resMsg := apis.ApprovedMessage{Decision: tmaxv1.DecisionUnknown, Response: UnauthorizedMessage + err.Error()}
+		if err := enc.Encode(resMsg); err != nil {
+			log.Log.Error(err, "Cannot reply request")
+		}
+		return
+	}
+
 	var m apis.ApprovedMessage
-	err := json.NewDecoder(r.Body).Decode(&m)
+	err = json.Unmarshal(body, &m)
 	if err != nil {
-		panic(err.Error())
+		log.Log.Error(err, "Cannot decode the message")
+		w.WriteHeader(http.StatusBadRequest)
+		resMsg := apis.ApprovedMessage{Decision: tmaxv1.DecisionUnknown, Response: UnknownMessage + err.Error()}
+		if err := enc.Encode(resMsg); err != nil {
+			log.Log.Error(err, "Cannot reply request")
+		}
+		return
 	}
 
-	exitCode := 0
-	enc := json.NewEncoder(w)
-	w.Header().Set("Content-Type", "application/json")
+	// Print the comments of the approvers to the pipeline log
+	for _, c := range m.Comments {
+		by := c.UserID
+		if c.OnBehalfOf != "" {
+			by = fmt.Sprintf("%s on behalf of %s", c.UserID, c.OnBehalfOf)
+		}
+		log.Log.Info(fmt.Sprintf("%s by %s, reason: %s, comment: %s", c.Decision, by, c.Reason, c.Comment))
+	}
+
+	// Changes requested, the approval is still in progress
+	if m.Decision == tmaxv1.DecisionRequestChanges {
+		log.Log.Info(fmt.Sprintf("Changes requested by %s", m.UserID))
+		resMsg := apis.ApprovedMessage{Decision: m.Decision, Response: RequestChangesMessage}
+		if err := enc.Encode(resMsg); err != nil {
+			log.Log.Error(err, "Cannot reply request")
+		}
+		return
+	}
 
 	var msg string
 	if m.Decision == tmaxv1.DecisionApproved {
@@ -51,6 +104,7 @@ func messageHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		log.Log.Info("Message: " + UnknownMessage)
 		resMsg := apis.ApprovedMessage{Decision: tmaxv1.DecisionUnknown, Response: UnknownMessage + string(m.Decision)}
+		w.WriteHeader(http.StatusBadRequest)
 		err = enc.Encode(resMsg)
 		if err != nil {
 			panic(err.Error())
@@ -94,20 +148,31 @@ func Users() (map[string]string, error) {
 	return users, nil
 }
 
-func CreateApproval() error {
+// requestID returns the idempotency key of the approval requested by this pod.
+// It is the uid of the pod, given by the downward api as POD_UID (fieldRef: metadata.uid).
+// If it is not given, the name and the IP of the pod are used, which are stable while the pod lives
+func requestID(hostName, podIP string) string {
+	if uid := os.Getenv("POD_UID"); uid != "" {
+		return uid
+	}
+	return fmt.Sprintf("%s/%s", hostName, podIP)
+}
+
+// CreateApproval requests the operator to create an approval, returning the response and the private key of the server
+func CreateApproval() (*apis.PostApprovalResponse, []byte, error) {
 	namespace, err := internal.Namespace()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	hostName, err := os.Hostname()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	podIP, err := internal.LocalIP()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	var threshold int
@@ -117,13 +182,32 @@ func CreateApproval() error {
 	} else {
 		threshold, err = strconv.Atoi(thresEnv)
 		if err != nil {
-			return errors.New("wrong threshold: " + thresEnv)
+			return nil, nil, errors.New("wrong threshold: " + thresEnv)
+		}
+	}
+
+	var timeout *metav1.Duration
+	if timeoutEnv := os.Getenv("TIMEOUT"); timeoutEnv != "" {
+		d, err := time.ParseDuration(timeoutEnv)
+		if err != nil {
+			return nil, nil, errors.New("wrong timeout: " + timeoutEnv)
 		}
+		timeout = &metav1.Duration{Duration: d}
 	}
 
 	users, err := Users()
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	// Generate the key of the server, and request the operator to issue its certificate, if https is used
+	var key, csr []byte
+	scheme := os.Getenv("SCHEME")
+	if scheme == tmaxv1.SchemeHTTPS {
+		key, csr, err = callback.NewCertificateRequest(hostName)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	msg := apis.PostApprovalMessage{
@@ -134,33 +218,87 @@ func CreateApproval() error {
 		Port:       Port,
 		Threshold:  int32(threshold),
 		Users:      users,
+		Scheme:     scheme,
+		CSR:        csr,
+		// Retried requests from this pod should not create another approval
+		RequestID: requestID(hostName, podIP),
+
+		Timeout:         timeout,
+		DefaultDecision: tmaxv1.DecisionType(os.Getenv("DEFAULT_DECISION")),
 	}
 
 	msgByte, err := json.Marshal(msg)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	// Authenticate to the operator with the service account token
+	token, err := internal.ServiceAccountToken()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	buff := bytes.NewBuffer(msgByte)
-	_, err = http.Post(OperatorSvcAddr, "application/json", buff)
+	req, err := http.NewRequest("POST", OperatorSvcAddr, buff)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errMsg := apis.ErrorMessage{}
+		_ = json.NewDecoder(resp.Body).Decode(&errMsg)
+		return nil, nil, fmt.Errorf("operator replied with status %d: %s", resp.StatusCode, errMsg.Error)
+	}
+
+	result := &apis.PostApprovalResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, nil, err
+	}
+	if len(result.CallbackKey) == 0 {
+		return nil, nil, errors.New("operator did not give callback key")
 	}
 
-	return nil
+	return result, key, nil
 }
 
 func main() {
 	// create Approval
-	err := CreateApproval()
+	created, key, err := CreateApproval()
 	if err != nil {
 		panic(err.Error())
 	}
+	verifier = callback.NewVerifier(created.CallbackKey)
 
 	router := mux.NewRouter()
 	router.HandleFunc("/", messageHandler).Methods("PUT")
 
 	http.Handle("/", router)
+
+	// Serve https with the certificate issued by the operator
+	if created.Scheme == tmaxv1.SchemeHTTPS {
+		cert, err := tls.X509KeyPair(created.TLSCert, key)
+		if err != nil {
+			panic(err.Error())
+		}
+		server := &http.Server{
+			Addr:      fmt.Sprintf(":%d", Port),
+			TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		}
+		err = server.ListenAndServeTLS("", "")
+		if err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+
 	err = http.ListenAndServe(fmt.Sprintf(":%d", Port), nil)
 	if err != nil {
 		panic(err.Error())
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"strings"
 )
 
 func Namespace() (string, error) {
@@ -26,6 +27,16 @@ func Namespace() (string, error) {
 	}
 }
 
+// ServiceAccountToken returns the token of the service account the pod is running with
+func ServiceAccountToken() (string, error) {
+	tokenPath := "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	tokenBytes, err := ioutil.ReadFile(tokenPath)
+	if err != nil {
+		return "", errors.New("could not read file " + tokenPath)
+	}
+	return strings.TrimSpace(string(tokenBytes)), nil
+}
+
 // GetLocalIP returns the non loopback local IP of the host
 func LocalIP() (string, error) {
 	addrs, err := net.InterfaceAddrs()
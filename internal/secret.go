@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EnsureSecret returns the secret of the key, creating the one built by newSecret if it does not exist.
+// newSecret is called only if the secret does not exist, and the name and the namespace of the key are set to its result
+func EnsureSecret(ctx context.Context, c client.Client, key types.NamespacedName, newSecret func() (*corev1.Secret, error)) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, key, secret)
+	if err == nil {
+		return secret, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	secret, err = newSecret()
+	if err != nil {
+		return nil, err
+	}
+	secret.Name = key.Name
+	secret.Namespace = key.Namespace
+	if err := c.Create(ctx, secret); err != nil {
+		if !k8serrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		// Created by another request in the meantime
+		secret = &corev1.Secret{}
+		if err := c.Get(ctx, key, secret); err != nil {
+			return nil, err
+		}
+	}
+	return secret, nil
+}
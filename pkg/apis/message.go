@@ -1,10 +1,28 @@
 package apis
 
-import tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+import (
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
 
 type ApprovedMessage struct {
 	Decision tmaxv1.DecisionType `json:"decision"`
 	Response string              `json:"response"`
+	// UserID is the user requested changes, if the decision is RequestChanges
+	UserID string `json:"userId,omitempty"`
+	// Comments are the comments of the approvers on their decisions
+	Comments []Comment `json:"comments,omitempty"`
+}
+
+// Comment is the justification of a decision made by an approver
+type Comment struct {
+	UserID     string              `json:"userId"`
+	OnBehalfOf string              `json:"onBehalfOf,omitempty"`
+	Decision   tmaxv1.DecisionType `json:"decision"`
+	Reason     string              `json:"reason,omitempty"`
+	Comment    string              `json:"comment,omitempty"`
 }
 
 type PostApprovalMessage struct {
@@ -15,4 +33,56 @@ type PostApprovalMessage struct {
 	AccessPath string            `json:"accessPath"`
 	Port       int32             `json:"port"`
 	Users      map[string]string `json:"users"`
+	Scheme     string            `json:"scheme,omitempty"`
+	// CSR is the PEM encoded certificate request of the task, required if the scheme is https.
+	// The private key of the task never leaves the task
+	CSR []byte `json:"csr,omitempty"`
+
+	Groups       []tmaxv1.GroupApprover `json:"groups,omitempty"`
+	AccessReview *tmaxv1.AccessReview   `json:"accessReview,omitempty"`
+	Weights      map[string]int32       `json:"weights,omitempty"`
+	Quorum       *tmaxv1.Quorum         `json:"quorum,omitempty"`
+
+	RejectionRule   tmaxv1.RejectionRule   `json:"rejectionRule,omitempty"`
+	RejectThreshold int32                  `json:"rejectThreshold,omitempty"`
+	Stages          []tmaxv1.ApprovalStage `json:"stages,omitempty"`
+
+	// RequestID is an idempotency key. Requests with the same id result in a single approval
+	RequestID string `json:"requestId,omitempty"`
+
+	Timeout         *metav1.Duration    `json:"timeout,omitempty"`
+	DefaultDecision tmaxv1.DecisionType `json:"defaultDecision,omitempty"`
+
+	RequireRejectionReason bool                    `json:"requireRejectionReason,omitempty"`
+	Escalations            []tmaxv1.EscalationStep `json:"escalations,omitempty"`
+	ReminderInterval       *metav1.Duration        `json:"reminderInterval,omitempty"`
+}
+
+type PostApprovalResponse struct {
+	PostApprovalMessage
+
+	// Name and UID of the created approval
+	Name string    `json:"name"`
+	UID  types.UID `json:"uid"`
+
+	// CallbackKey is the key to verify the signature of the messages sent from the operator
+	CallbackKey []byte `json:"callbackKey"`
+
+	// TLSCert is the server certificate of the task issued for the CSR, if the scheme is https
+	TLSCert []byte `json:"tlsCert,omitempty"`
+}
+
+type GetApprovalResponse struct {
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace"`
+	UID        types.UID         `json:"uid"`
+	Conditions tmaxv1.Conditions `json:"conditions,omitempty"`
+	Approvers  []tmaxv1.Approver `json:"approvers,omitempty"`
+
+	CurrentStage int32                `json:"currentStage,omitempty"`
+	Stages       []tmaxv1.StageStatus `json:"stages,omitempty"`
+}
+
+type ErrorMessage struct {
+	Error string `json:"error"`
 }
@@ -2,21 +2,67 @@ package v1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"time"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+
+	// RequestIDAnnotation holds the idempotency key of the request created the approval
+	RequestIDAnnotation = "tmax.io/request-id"
+)
+
 // ApprovalSpec defines the desired state of Approval
 type ApprovalSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
-	PodIP      string            `json:"podIP"`
-	AccessPath string            `json:"accessPath,omitempty"`
-	Port       int32             `json:"port,omitempty"`
-	Threshold  int32             `json:"threshold,omitempty"`
-	Users      map[string]string `json:"users"`
+	PodIP      string `json:"podIP"`
+	AccessPath string `json:"accessPath,omitempty"`
+	Port       int32  `json:"port,omitempty"`
+
+	// Users and Threshold of a single stage approval. Should be empty if Stages are specified
+	ApproverPolicy `json:",inline"`
+
+	// Stages are approved sequentially. The approval is approved only if all of the stages are approved
+	// +optional
+	Stages []ApprovalStage `json:"stages,omitempty"`
+
+	// Scheme is the protocol used to send the decision to the task, one of http or https
+	// +optional
+	// +kubebuilder:validation:Enum=http;https
+	// +kubebuilder:default:=http
+	Scheme string `json:"scheme,omitempty"`
+
+	// Timeout is the duration after creation, after which the approval expires
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Deadline is the time at which the approval expires. It takes precedence over Timeout
+	// +optional
+	Deadline *metav1.Time `json:"deadline,omitempty"`
+
+	// DefaultDecision is sent to the task when the approval expires
+	// +optional
+	// +kubebuilder:default:=Rejected
+	DefaultDecision DecisionType `json:"defaultDecision,omitempty"`
+
+	// RequireRejectionReason makes the reason of the approver entry required for rejections
+	// +optional
+	RequireRejectionReason bool `json:"requireRejectionReason,omitempty"`
+
+	// Escalations widen the approvers of the stage in progress, if it is still waiting after each step's duration
+	// +optional
+	Escalations []EscalationStep `json:"escalations,omitempty"`
+
+	// ReminderInterval is the interval to remind the users who have not decided yet, while the approval is waiting
+	// +optional
+	ReminderInterval *metav1.Duration `json:"reminderInterval,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -24,6 +70,8 @@ type ApprovalSpec struct {
 // Approval is the Schema for the approvals API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=approvals,scope=Namespaced
+// +kubebuilder:printcolumn:name="Tally",type=string,JSONPath=`.status.tally.summary`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type Approval struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -32,6 +80,82 @@ type Approval struct {
 	Status ApprovalStatus `json:"status,omitempty"`
 }
 
+// ExpiryTime returns the time at which the approval expires, or nil if it never expires
+func (a *Approval) ExpiryTime() *time.Time {
+	if a.Spec.Deadline != nil {
+		t := a.Spec.Deadline.Time
+		return &t
+	}
+	if a.Spec.Timeout != nil {
+		t := a.CreationTimestamp.Add(a.Spec.Timeout.Duration)
+		return &t
+	}
+	return nil
+}
+
+// NextReminderTime returns the time to remind the users who have not decided yet, or nil if reminders are disabled.
+// The first reminder is after the interval since the stage in progress started
+func (a *Approval) NextReminderTime() *time.Time {
+	if a.Spec.ReminderInterval == nil || a.Spec.ReminderInterval.Duration <= 0 {
+		return nil
+	}
+	last := a.WaitingSince()
+	if a.Status.LastReminderTime != nil && a.Status.LastReminderTime.After(last) {
+		last = a.Status.LastReminderTime.Time
+	}
+	t := last.Add(a.Spec.ReminderInterval.Duration)
+	return &t
+}
+
+// PendingUsers returns the users of the stage in progress who have not decided yet
+func (a *Approval) PendingUsers() map[string]string {
+	pending := map[string]string{}
+	for u, email := range a.ActivePolicy().Users {
+		if a.Status.GetEffectiveApprover(u) == nil {
+			pending[u] = email
+		}
+	}
+	return pending
+}
+
+// ActivePolicy returns the approver policy of the stage in progress, including the approvers escalated.
+// If stages are not specified, the approval is regarded as a single stage approval
+func (a *Approval) ActivePolicy() *ApproverPolicy {
+	if len(a.Spec.Stages) == 0 {
+		return a.escalate(&a.Spec.ApproverPolicy)
+	}
+	return a.escalate(&a.Spec.Stages[a.activeStageIndex()].ApproverPolicy)
+}
+
+// IsLastStage is true if the stage in progress is the last one
+func (a *Approval) IsLastStage() bool {
+	return len(a.Spec.Stages) == 0 || a.activeStageIndex() == len(a.Spec.Stages)-1
+}
+
+func (a *Approval) activeStageIndex() int {
+	i := int(a.Status.CurrentStage)
+	if i < 0 {
+		return 0
+	}
+	if i >= len(a.Spec.Stages) {
+		return len(a.Spec.Stages) - 1
+	}
+	return i
+}
+
+// IsTLS is true if the decision should be sent to the task over https
+func (a *Approval) IsTLS() bool {
+	return a.Spec.Scheme == SchemeHTTPS
+}
+
+// ExpiryDecision returns the decision to be sent to the task when the approval expires
+func (a *Approval) ExpiryDecision() DecisionType {
+	if a.Spec.DefaultDecision == "" {
+		return DecisionRejected
+	}
+	return a.Spec.DefaultDecision
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ApprovalList contains a list of Approval
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApproval_NextReminderTime(t *testing.T) {
+	created := time.Unix(1000, 0)
+	reminded := metav1.NewTime(created.Add(90 * time.Minute))
+
+	tc := map[string]struct {
+		interval *metav1.Duration
+		last     *metav1.Time
+		expected *time.Time
+	}{
+		"disabled": {},
+		"first": {
+			interval: &metav1.Duration{Duration: time.Hour},
+			expected: timePtr(created.Add(time.Hour)),
+		},
+		"afterReminded": {
+			interval: &metav1.Duration{Duration: time.Hour},
+			last:     &reminded,
+			expected: timePtr(reminded.Add(time.Hour)),
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			a := &Approval{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+				Spec:       ApprovalSpec{ReminderInterval: c.interval},
+				Status:     ApprovalStatus{LastReminderTime: c.last},
+			}
+			next := a.NextReminderTime()
+			if (next == nil) != (c.expected == nil) || (next != nil && !next.Equal(*c.expected)) {
+				t.Fatalf("expected %v, got %v", c.expected, next)
+			}
+		})
+	}
+}
+
+func TestApproval_PendingUsers(t *testing.T) {
+	a := &Approval{
+		Spec: ApprovalSpec{
+			ApproverPolicy: ApproverPolicy{
+				Threshold: 2,
+				Users:     map[string]string{"alice": "alice@tmax.co.kr", "bob": "bob@tmax.co.kr", "carol": "carol@tmax.co.kr"},
+			},
+		},
+		Status: ApprovalStatus{
+			Approvers: []Approver{
+				{UserID: "alice", Decision: DecisionApproved},
+				{UserID: "erin", Decision: DecisionRejected, OnBehalfOf: "bob"},
+			},
+		},
+	}
+
+	pending := a.PendingUsers()
+	if len(pending) != 1 || pending["carol"] != "carol@tmax.co.kr" {
+		t.Fatalf("expected only carol to be pending, got %v", pending)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
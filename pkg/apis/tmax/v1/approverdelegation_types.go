@@ -0,0 +1,60 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"time"
+)
+
+// ApproverDelegationSpec defines the user delegating the decisions and the delegate
+type ApproverDelegationSpec struct {
+	// User is the approver delegating the decisions
+	User string `json:"user"`
+	// Delegate is the user who can decide on behalf of the user
+	Delegate string `json:"delegate"`
+
+	// ValidFrom is the time the delegation starts. Starts immediately if not specified
+	// +optional
+	ValidFrom *metav1.Time `json:"validFrom,omitempty"`
+	// ValidUntil is the time the delegation ends. Never ends if not specified
+	// +optional
+	ValidUntil *metav1.Time `json:"validUntil,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ApproverDelegation is the Schema for the approverdelegations API
+// +kubebuilder:resource:path=approverdelegations,scope=Cluster
+// +kubebuilder:printcolumn:name="User",type=string,JSONPath=`.spec.user`
+// +kubebuilder:printcolumn:name="Delegate",type=string,JSONPath=`.spec.delegate`
+// +kubebuilder:printcolumn:name="Until",type=string,JSONPath=`.spec.validUntil`
+type ApproverDelegation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ApproverDelegationSpec `json:"spec,omitempty"`
+}
+
+// IsValidAt is true if the delegation is valid at the time
+func (d *ApproverDelegation) IsValidAt(t time.Time) bool {
+	if d.Spec.ValidFrom != nil && t.Before(d.Spec.ValidFrom.Time) {
+		return false
+	}
+	if d.Spec.ValidUntil != nil && !t.Before(d.Spec.ValidUntil.Time) {
+		return false
+	}
+	return true
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ApproverDelegationList contains a list of ApproverDelegation
+type ApproverDelegationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApproverDelegation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ApproverDelegation{}, &ApproverDelegationList{})
+}
@@ -9,10 +9,12 @@ type Conditions []Condition
 type ConditionType string
 
 const (
-	ConditionWaiting  ConditionType = "Waiting"
-	ConditionApproved ConditionType = "Approved"
-	ConditionRejected ConditionType = "Rejected"
-	ConditionFailed   ConditionType = "Failed"
+	ConditionWaiting   ConditionType = "Waiting"
+	ConditionApproved  ConditionType = "Approved"
+	ConditionRejected  ConditionType = "Rejected"
+	ConditionFailed    ConditionType = "Failed"
+	ConditionExpired   ConditionType = "Expired"
+	ConditionCancelled ConditionType = "Cancelled"
 )
 
 // to seperate conditions and our status. conditions will be replaced by knative.conditions
@@ -0,0 +1,99 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"time"
+)
+
+// EscalationStep widens the approvers if the stage in progress is still waiting after the duration
+type EscalationStep struct {
+	// After is the duration since the stage in progress started, after which the step is applied
+	After metav1.Duration `json:"after"`
+
+	// Users who can approve after escalation
+	// +optional
+	Users map[string]string `json:"users,omitempty"`
+
+	// Groups whose members can approve after escalation. Their decisions are counted toward the threshold
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+// EscalationStatus is an escalation step applied
+type EscalationStatus struct {
+	// Level is the number of escalation steps applied to the stage, including this one
+	Level int32 `json:"level"`
+	// Stage is the index of the stage escalated
+	// +optional
+	Stage int32 `json:"stage,omitempty"`
+	// Time is when the step is applied
+	Time metav1.Time `json:"time"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// WaitingSince returns the time the stage in progress started
+func (a *Approval) WaitingSince() time.Time {
+	if i := a.activeStageIndex(); len(a.Spec.Stages) > 0 && i < len(a.Status.Stages) {
+		return a.Status.Stages[i].StartTime.Time
+	}
+	return a.CreationTimestamp.Time
+}
+
+// DueEscalationLevel returns the number of escalation steps to be applied at the time
+func (a *Approval) DueEscalationLevel(t time.Time) int32 {
+	since := a.WaitingSince()
+	var level int32
+	for _, step := range a.Spec.Escalations {
+		if t.Before(since.Add(step.After.Duration)) {
+			break
+		}
+		level++
+	}
+	return level
+}
+
+// NextEscalationTime returns the time the next escalation step is applied, or nil if there is no more step
+func (a *Approval) NextEscalationTime() *time.Time {
+	i := int(a.Status.EscalationLevel)
+	if i < 0 || i >= len(a.Spec.Escalations) {
+		return nil
+	}
+	t := a.WaitingSince().Add(a.Spec.Escalations[i].After.Duration)
+	return &t
+}
+
+// escalate merges the users and groups of the escalation steps applied into the policy
+func (a *Approval) escalate(policy *ApproverPolicy) *ApproverPolicy {
+	level := int(a.Status.EscalationLevel)
+	if level <= 0 {
+		return policy
+	}
+	if level > len(a.Spec.Escalations) {
+		level = len(a.Spec.Escalations)
+	}
+
+	escalated := policy.DeepCopy()
+	for _, step := range a.Spec.Escalations[:level] {
+		for u, email := range step.Users {
+			if escalated.Users == nil {
+				escalated.Users = map[string]string{}
+			}
+			if _, exist := escalated.Users[u]; !exist {
+				escalated.Users[u] = email
+				if escalated.EscalatedUsers == nil {
+					escalated.EscalatedUsers = map[string]bool{}
+				}
+				escalated.EscalatedUsers[u] = true
+			}
+		}
+		// Escalated groups don't have their own threshold
+		for _, g := range step.Groups {
+			if escalated.GetGroup(g) == nil {
+				escalated.Groups = append(escalated.Groups, GroupApprover{Name: g})
+			}
+		}
+	}
+	return escalated
+}
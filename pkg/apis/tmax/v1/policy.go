@@ -0,0 +1,205 @@
+package v1
+
+import (
+	"fmt"
+)
+
+// Weight returns the voting weight of the user
+func (p *ApproverPolicy) Weight(user string) int32 {
+	if w, exist := p.Weights[user]; exist {
+		return w
+	}
+	return 1
+}
+
+// IsEmpty is true if nothing is specified in the policy
+func (p *ApproverPolicy) IsEmpty() bool {
+	return p.Threshold == 0 && len(p.Users) == 0 && len(p.Groups) == 0 && p.AccessReview == nil && len(p.Weights) == 0 && p.Quorum == nil &&
+		p.RejectionRule == "" && p.RejectThreshold == 0
+}
+
+// Tally counts the decisions of the approvers
+func (p *ApproverPolicy) Tally(approvers []Approver) Tally {
+	t := Tally{Required: p.Threshold}
+	for _, a := range approvers {
+		switch a.Decision {
+		case DecisionApproved:
+			t.Approved++
+			t.ApprovedWeight += p.Weight(a.EffectiveUser())
+		case DecisionRejected:
+			t.Rejected++
+		case DecisionAbstain:
+			t.Abstained++
+		case DecisionRequestChanges:
+			t.ChangesRequested++
+		}
+	}
+	t.Summary = fmt.Sprintf("%d/%d approved, %d rejected", t.Approved, t.Required, t.Rejected)
+	if t.Abstained > 0 {
+		t.Summary += fmt.Sprintf(", %d abstained", t.Abstained)
+	}
+	if t.ChangesRequested > 0 {
+		t.Summary += fmt.Sprintf(", %d requested changes", t.ChangesRequested)
+	}
+	return t
+}
+
+// IsRejected is true if the decisions satisfy the rejection rule of the policy
+func (p *ApproverPolicy) IsRejected(approvers []Approver) bool {
+	t := p.Tally(approvers)
+	if p.RejectionRule == RejectionThreshold {
+		return t.Rejected >= p.RejectThreshold
+	}
+	return t.Rejected > 0
+}
+
+// Evaluate is true if the approvals satisfy the thresholds and the quorum of the policy.
+// Only the approvers whose decision is Approved are counted
+func (p *ApproverPolicy) Evaluate(approvers []Approver) bool {
+	t := p.Tally(approvers)
+	approved := map[string]Approver{}
+	for _, a := range approvers {
+		if a.Decision == DecisionApproved {
+			approved[a.EffectiveUser()] = a
+		}
+	}
+
+	// Threshold
+	if t.Approved < p.Threshold {
+		return false
+	}
+
+	// Thresholds of groups
+	for _, g := range p.Groups {
+		count := 0
+		for _, a := range approved {
+			if a.Group == g.Name {
+				count++
+			}
+		}
+		if count < int(g.Threshold) {
+			return false
+		}
+	}
+
+	if p.Quorum == nil {
+		return true
+	}
+
+	// Total weight
+	if t.ApprovedWeight < p.Quorum.Weight {
+		return false
+	}
+
+	// Percentage of the users requested, not including the ones escalated to
+	count, total := 0, 0
+	for u := range p.Users {
+		if p.EscalatedUsers[u] {
+			continue
+		}
+		total++
+		if _, exist := approved[u]; exist {
+			count++
+		}
+	}
+	if count*100 < int(p.Quorum.Percentage)*total {
+		return false
+	}
+
+	// Users who must approve
+	for _, u := range p.Quorum.MustInclude {
+		if _, exist := approved[u]; !exist {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validate validates users, groups, weights, threshold and quorum of the policy
+func (p *ApproverPolicy) Validate() error {
+	// Number of users or groups should be greater than 0, if access review is not used
+	if len(p.Users) < 1 && len(p.Groups) < 1 && p.AccessReview == nil {
+		return fmt.Errorf("there should be one or more users or groups specified, or access review should be used")
+	}
+
+	// Number of group members or users permitted by RBAC is unknown, so the upper bounds are checked only if users are the only approvers
+	bounded := len(p.Groups) == 0 && p.AccessReview == nil
+
+	// Threshold should be greater or equal to 1, less or equal to len(users)
+	if p.Threshold < 1 || (bounded && int(p.Threshold) > len(p.Users)) {
+		return fmt.Errorf("threshold(%d) should be greater or equal to 1, less or equal to the length of users", p.Threshold)
+	}
+
+	// Group threshold should be greater or equal to 1
+	names := map[string]bool{}
+	for _, g := range p.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("name of a group should not be empty")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicated group name(%s)", g.Name)
+		}
+		names[g.Name] = true
+
+		if g.Threshold < 1 {
+			return fmt.Errorf("threshold(%d) of group(%s) should be greater or equal to 1", g.Threshold, g.Name)
+		}
+	}
+
+	// Weight should be greater or equal to 1
+	for u, w := range p.Weights {
+		if w < 1 {
+			return fmt.Errorf("weight(%d) of user(%s) should be greater or equal to 1", w, u)
+		}
+	}
+
+	// Reject threshold should be greater or equal to 1, only if rejection rule is Threshold
+	switch p.RejectionRule {
+	case "", RejectionAnyVeto:
+		if p.RejectThreshold != 0 {
+			return fmt.Errorf("reject threshold should be specified only if rejection rule is %s", RejectionThreshold)
+		}
+	case RejectionThreshold:
+		if p.RejectThreshold < 1 {
+			return fmt.Errorf("reject threshold(%d) should be greater or equal to 1", p.RejectThreshold)
+		}
+	default:
+		return fmt.Errorf("rejection rule(%s) should be one of %s or %s", p.RejectionRule, RejectionAnyVeto, RejectionThreshold)
+	}
+
+	if p.Quorum == nil {
+		return nil
+	}
+
+	// Quorum weight should be reachable
+	if p.Quorum.Weight < 0 {
+		return fmt.Errorf("quorum weight(%d) should not be negative", p.Quorum.Weight)
+	}
+	if bounded {
+		var total int32
+		for u := range p.Users {
+			total += p.Weight(u)
+		}
+		if p.Quorum.Weight > total {
+			return fmt.Errorf("quorum weight(%d) should be less or equal to the total weight(%d) of users", p.Quorum.Weight, total)
+		}
+	}
+
+	// Percentage should be in range of 0-100, and counts the users
+	if p.Quorum.Percentage < 0 || p.Quorum.Percentage > 100 {
+		return fmt.Errorf("quorum percentage(%d) is not in range of 0-100", p.Quorum.Percentage)
+	}
+	if p.Quorum.Percentage > 0 && len(p.Users) == 0 {
+		return fmt.Errorf("quorum percentage needs users to be specified")
+	}
+
+	// Users who must approve should be one of the users
+	for _, u := range p.Quorum.MustInclude {
+		if _, exist := p.Users[u]; !exist {
+			return fmt.Errorf("user(%s) in quorum mustInclude is not in users", u)
+		}
+	}
+
+	return nil
+}
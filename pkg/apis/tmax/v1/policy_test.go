@@ -0,0 +1,161 @@
+package v1
+
+import (
+	"testing"
+)
+
+func TestApproverPolicy_Evaluate(t *testing.T) {
+	users := map[string]string{"alice": "", "bob": "", "carol": "", "dave": ""}
+	approved := func(users ...string) []Approver {
+		var approvers []Approver
+		for _, u := range users {
+			approvers = append(approvers, Approver{UserID: u, Decision: DecisionApproved})
+		}
+		return approvers
+	}
+
+	tc := map[string]struct {
+		policy    ApproverPolicy
+		approvers []Approver
+		expected  bool
+	}{
+		"threshold": {
+			policy:    ApproverPolicy{Threshold: 2, Users: users},
+			approvers: approved("alice", "bob"),
+			expected:  true,
+		},
+		"thresholdNotMet": {
+			policy:    ApproverPolicy{Threshold: 2, Users: users},
+			approvers: approved("alice"),
+			expected:  false,
+		},
+		"rejectedNotCounted": {
+			policy:    ApproverPolicy{Threshold: 2, Users: users},
+			approvers: append(approved("alice"), Approver{UserID: "bob", Decision: DecisionRejected}),
+			expected:  false,
+		},
+		"group": {
+			policy:    ApproverPolicy{Threshold: 1, Groups: []GroupApprover{{Name: "sre", Threshold: 2}}},
+			approvers: []Approver{{UserID: "x", Decision: DecisionApproved, Group: "sre"}, {UserID: "y", Decision: DecisionApproved}},
+			expected:  false,
+		},
+		"weight": {
+			policy:    ApproverPolicy{Threshold: 1, Users: users, Weights: map[string]int32{"alice": 3}, Quorum: &Quorum{Weight: 4}},
+			approvers: approved("alice", "bob"),
+			expected:  true,
+		},
+		"weightNotMet": {
+			policy:    ApproverPolicy{Threshold: 1, Users: users, Weights: map[string]int32{"alice": 3}, Quorum: &Quorum{Weight: 4}},
+			approvers: approved("bob", "carol", "dave"),
+			expected:  false,
+		},
+		"percentage": {
+			policy:    ApproverPolicy{Threshold: 1, Users: users, Quorum: &Quorum{Percentage: 75}},
+			approvers: approved("alice", "bob", "carol"),
+			expected:  true,
+		},
+		"percentageNotMet": {
+			policy:    ApproverPolicy{Threshold: 1, Users: users, Quorum: &Quorum{Percentage: 75}},
+			approvers: approved("alice", "bob"),
+			expected:  false,
+		},
+		"percentageWithEscalated": {
+			policy:    ApproverPolicy{Threshold: 1, Users: users, EscalatedUsers: map[string]bool{"dave": true}, Quorum: &Quorum{Percentage: 75}},
+			approvers: approved("alice", "bob", "carol"),
+			expected:  true,
+		},
+		"percentageByEscalated": {
+			policy:    ApproverPolicy{Threshold: 1, Users: users, EscalatedUsers: map[string]bool{"carol": true, "dave": true}, Quorum: &Quorum{Percentage: 75}},
+			approvers: approved("alice", "carol", "dave"),
+			expected:  false,
+		},
+		"mustInclude": {
+			policy:    ApproverPolicy{Threshold: 2, Users: users, Quorum: &Quorum{MustInclude: []string{"alice"}}},
+			approvers: approved("bob", "carol"),
+			expected:  false,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			if got := c.policy.Evaluate(c.approvers); got != c.expected {
+				t.Fatalf("expected %t, got %t", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestApproverPolicy_Tally(t *testing.T) {
+	policy := ApproverPolicy{Threshold: 3, Weights: map[string]int32{"alice": 2}}
+	approvers := []Approver{
+		{UserID: "alice", Decision: DecisionApproved},
+		{UserID: "bob", Decision: DecisionApproved},
+		{UserID: "carol", Decision: DecisionRejected},
+		{UserID: "dave", Decision: DecisionAbstain},
+		{UserID: "eve", Decision: DecisionRequestChanges},
+	}
+
+	got := policy.Tally(approvers)
+	expected := Tally{Approved: 2, Rejected: 1, Abstained: 1, ChangesRequested: 1, ApprovedWeight: 3, Required: 3,
+		Summary: "2/3 approved, 1 rejected, 1 abstained, 1 requested changes"}
+	if got != expected {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestApproverPolicy_IsRejected(t *testing.T) {
+	approvers := []Approver{
+		{UserID: "alice", Decision: DecisionApproved},
+		{UserID: "bob", Decision: DecisionRejected},
+	}
+
+	tc := map[string]struct {
+		policy   ApproverPolicy
+		expected bool
+	}{
+		"default":         {policy: ApproverPolicy{}, expected: true},
+		"anyVeto":         {policy: ApproverPolicy{RejectionRule: RejectionAnyVeto}, expected: true},
+		"threshold":       {policy: ApproverPolicy{RejectionRule: RejectionThreshold, RejectThreshold: 1}, expected: true},
+		"thresholdNotMet": {policy: ApproverPolicy{RejectionRule: RejectionThreshold, RejectThreshold: 2}, expected: false},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			if got := c.policy.IsRejected(approvers); got != c.expected {
+				t.Fatalf("expected %t, got %t", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestApproverPolicy_Validate(t *testing.T) {
+	users := map[string]string{"alice": "", "bob": ""}
+
+	tc := map[string]struct {
+		policy ApproverPolicy
+		valid  bool
+	}{
+		"valid":              {policy: ApproverPolicy{Threshold: 2, Users: users, Weights: map[string]int32{"alice": 2}, Quorum: &Quorum{Weight: 3, Percentage: 50, MustInclude: []string{"alice"}}}, valid: true},
+		"noApprovers":        {policy: ApproverPolicy{Threshold: 1}, valid: false},
+		"thresholdOverUser":  {policy: ApproverPolicy{Threshold: 3, Users: users}, valid: false},
+		"groupThreshold":     {policy: ApproverPolicy{Threshold: 3, Groups: []GroupApprover{{Name: "sre"}}}, valid: false},
+		"zeroWeight":         {policy: ApproverPolicy{Threshold: 1, Users: users, Weights: map[string]int32{"bob": 0}}, valid: false},
+		"unreachableWeight":  {policy: ApproverPolicy{Threshold: 1, Users: users, Quorum: &Quorum{Weight: 3}}, valid: false},
+		"percentageRange":    {policy: ApproverPolicy{Threshold: 1, Users: users, Quorum: &Quorum{Percentage: 101}}, valid: false},
+		"unknownMustInclude": {policy: ApproverPolicy{Threshold: 1, Users: users, Quorum: &Quorum{MustInclude: []string{"carol"}}}, valid: false},
+		"rejectThreshold":    {policy: ApproverPolicy{Threshold: 1, Users: users, RejectionRule: RejectionThreshold}, valid: false},
+		"anyVetoThreshold":   {policy: ApproverPolicy{Threshold: 1, Users: users, RejectThreshold: 1}, valid: false},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			err := c.policy.Validate()
+			if c.valid && err != nil {
+				t.Fatalf("expected to be valid, got error: %s", err.Error())
+			}
+			if !c.valid && err == nil {
+				t.Fatal("expected to be invalid")
+			}
+		})
+	}
+}
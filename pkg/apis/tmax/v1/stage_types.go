@@ -0,0 +1,125 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApproverPolicy defines who can approve and how many approvals are needed
+type ApproverPolicy struct {
+	Threshold int32             `json:"threshold,omitempty"`
+	Users     map[string]string `json:"users,omitempty"`
+
+	// Groups are the identity groups whose members can approve, in addition to the users
+	// +optional
+	Groups []GroupApprover `json:"groups,omitempty"`
+
+	// AccessReview permits any user allowed by RBAC to approve, in addition to the users and groups
+	// +optional
+	AccessReview *AccessReview `json:"accessReview,omitempty"`
+
+	// Weights are the voting weights of the users. Users not listed have weight 1
+	// +optional
+	Weights map[string]int32 `json:"weights,omitempty"`
+
+	// Quorum is the rule to be satisfied by the approvals, in addition to the thresholds
+	// +optional
+	Quorum *Quorum `json:"quorum,omitempty"`
+
+	// RejectionRule decides when the stage is rejected, one of AnyVeto or Threshold. Defaults to AnyVeto
+	// +optional
+	// +kubebuilder:validation:Enum=AnyVeto;Threshold
+	RejectionRule RejectionRule `json:"rejectionRule,omitempty"`
+
+	// RejectThreshold is the number of rejections needed to reject the stage, if rejection rule is Threshold
+	// +optional
+	RejectThreshold int32 `json:"rejectThreshold,omitempty"`
+
+	// EscalatedUsers are the users added to users by escalation, not specified in the approval.
+	// They are counted toward the thresholds, but not toward the quorum percentage
+	EscalatedUsers map[string]bool `json:"-"`
+}
+
+// RejectionRule decides when the stage is rejected
+type RejectionRule string
+
+const (
+	// RejectionAnyVeto rejects the stage if anyone rejects it
+	RejectionAnyVeto RejectionRule = "AnyVeto"
+	// RejectionThreshold rejects the stage if the number of rejections is over the reject threshold
+	RejectionThreshold RejectionRule = "Threshold"
+)
+
+// Quorum is a rule to be satisfied by the approvals. Every specified rule should be satisfied
+type Quorum struct {
+	// Weight is the total weight of the approvals needed
+	// +optional
+	Weight int32 `json:"weight,omitempty"`
+
+	// Percentage is the percentage of the users in users field needed to approve
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percentage int32 `json:"percentage,omitempty"`
+
+	// MustInclude are the users who must approve
+	// +optional
+	MustInclude []string `json:"mustInclude,omitempty"`
+}
+
+// DefaultAccessReviewVerb is the virtual verb on approvals.tmax.io an approver should be permitted
+const DefaultAccessReviewVerb = "approve"
+
+// AccessReview decides approvers via SubjectAccessReview on a virtual verb of approvals.tmax.io
+type AccessReview struct {
+	// Verb is the virtual verb to be reviewed, e.g., approve
+	// +optional
+	// +kubebuilder:default:=approve
+	Verb string `json:"verb,omitempty"`
+
+	// ScopeToName reviews the verb on the approval's name, not on all approvals in the namespace
+	// +optional
+	ScopeToName bool `json:"scopeToName,omitempty"`
+}
+
+// GetVerb returns the verb to be reviewed
+func (r *AccessReview) GetVerb() string {
+	if r.Verb == "" {
+		return DefaultAccessReviewVerb
+	}
+	return r.Verb
+}
+
+// GroupApprover is an identity group, e.g., an OIDC/LDAP group passed by the api server
+type GroupApprover struct {
+	Name string `json:"name"`
+	// Threshold is the number of approvals needed from the members of the group
+	Threshold int32 `json:"threshold"`
+}
+
+// GetGroup returns the group approver of the name, or nil if it is not found
+func (p *ApproverPolicy) GetGroup(name string) *GroupApprover {
+	for i := range p.Groups {
+		if p.Groups[i].Name == name {
+			return &p.Groups[i]
+		}
+	}
+	return nil
+}
+
+// ApprovalStage is a step of the sequential approval chain
+type ApprovalStage struct {
+	Name string `json:"name"`
+
+	ApproverPolicy `json:",inline"`
+}
+
+// StageStatus is the result of a stage
+type StageStatus struct {
+	Name string `json:"name"`
+	// Result is one of Waiting, Approved or Rejected
+	Result         ConditionType `json:"result"`
+	StartTime      metav1.Time   `json:"startTime"`
+	CompletionTime *metav1.Time  `json:"completionTime,omitempty"`
+	// Approvers are the decisions made in the stage. Decisions of the stage in progress are in status.approvers
+	Approvers []Approver `json:"approvers,omitempty"`
+}
@@ -6,14 +6,18 @@ import (
 	"time"
 )
 
-// DecisionType field should have Approved or Rejected.
-// +kubebuilder:validation:Enum=Approved;Rejected
+// DecisionType field should have Approved, Rejected, Abstain or RequestChanges.
+// +kubebuilder:validation:Enum=Approved;Rejected;Abstain;RequestChanges
 type DecisionType string
 
 const (
 	DecisionApproved DecisionType = "Approved"
 	DecisionRejected DecisionType = "Rejected"
-	DecisionUnknown  DecisionType = "Unknown"
+	// DecisionAbstain counts as participation, but not as approval
+	DecisionAbstain DecisionType = "Abstain"
+	// DecisionRequestChanges is not final. It is delivered to the task, so that the task can react to it
+	DecisionRequestChanges DecisionType = "RequestChanges"
+	DecisionUnknown        DecisionType = "Unknown"
 )
 
 type ApprovalStatus struct {
@@ -22,12 +26,109 @@ type ApprovalStatus struct {
 	// +optional
 	// +kubebuilder:default:=0
 	Retry int32 `json:"retry"`
+	// LastRetryTime is the last time sending the decision to the task failed
+	// +optional
+	LastRetryTime *metav1.Time `json:"lastRetryTime,omitempty"`
+	// Response is the message replied by the task when the decision is sent
+	// +optional
+	Response string `json:"response,omitempty"`
+	// CurrentStage is the index of the stage in progress, if spec.stages are specified
+	// +optional
+	CurrentStage int32 `json:"currentStage,omitempty"`
+	// +optional
+	Stages []StageStatus `json:"stages,omitempty"`
+	// Tally is the count of the decisions of the stage in progress
+	// +optional
+	Tally *Tally `json:"tally,omitempty"`
+	// ChangeRequestsDelivered is the time of the change requests delivered to the task, by the users requested
+	// +optional
+	ChangeRequestsDelivered map[string]metav1.Time `json:"changeRequestsDelivered,omitempty"`
+	// EscalationLevel is the number of escalation steps applied to the stage in progress
+	// +optional
+	EscalationLevel int32 `json:"escalationLevel,omitempty"`
+	// Escalations are the escalation steps applied, of every stage
+	// +optional
+	Escalations []EscalationStatus `json:"escalations,omitempty"`
+	// LastReminderTime is the time the users who have not decided yet were reminded last
+	// +optional
+	LastReminderTime *metav1.Time `json:"lastReminderTime,omitempty"`
+	// RedeemedTokens are the ids of the decision tokens already used
+	// +optional
+	RedeemedTokens []string `json:"redeemedTokens,omitempty"`
+	// History is every vote cast or withdrawn, recorded by the operator
+	// +optional
+	History []VoteEvent `json:"history,omitempty"`
+}
+
+// VoteAction is an action of an approver on the vote
+// +kubebuilder:validation:Enum=Cast;Withdraw
+type VoteAction string
+
+const (
+	VoteCast     VoteAction = "Cast"
+	VoteWithdraw VoteAction = "Withdraw"
+)
+
+// VoteEvent is a vote cast or withdrawn by an approver
+type VoteEvent struct {
+	UserID string     `json:"userId"`
+	Action VoteAction `json:"action"`
+	// Decision is the decision cast. Empty if withdrawn
+	// +optional
+	Decision DecisionType `json:"decision,omitempty"`
+	// Time is when the operator observed the vote
+	Time metav1.Time `json:"time"`
+	// ApprovedTime is the time claimed by the approver in status.approvers. Empty if withdrawn
+	// +optional
+	ApprovedTime *metav1.Time `json:"approvedTime,omitempty"`
+	// OnBehalfOf is the user delegated the decision, if the vote is made by a delegate
+	// +optional
+	OnBehalfOf string `json:"onBehalfOf,omitempty"`
+	// Stage is the index of the stage the vote is made in
+	// +optional
+	Stage int32 `json:"stage,omitempty"`
+}
+
+// Tally is the count of the decisions made by the approvers
+type Tally struct {
+	Approved int32 `json:"approved"`
+	Rejected int32 `json:"rejected"`
+	// +optional
+	Abstained int32 `json:"abstained,omitempty"`
+	// +optional
+	ChangesRequested int32 `json:"changesRequested,omitempty"`
+	// ApprovedWeight is the total weight of the approvers approved
+	ApprovedWeight int32 `json:"approvedWeight"`
+	// Required is the number of approvals needed, i.e., the threshold
+	Required int32 `json:"required"`
+	// Summary is a human readable tally, e.g., 2/3 approved, 0 rejected
+	Summary string `json:"summary,omitempty"`
 }
 
 type Approver struct {
 	UserID       string       `json:"userId"`
 	Decision     DecisionType `json:"decision"`
 	ApprovedTime metav1.Time  `json:"approvedTime"`
+	// Group is the group through which the decision is counted. Empty if the user is specified in users
+	// +optional
+	Group string `json:"group,omitempty"`
+	// Reason is a short justification of the decision, e.g., SecurityConcern
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Comment is a free-form comment on the decision
+	// +optional
+	Comment string `json:"comment,omitempty"`
+	// OnBehalfOf is the user delegated the decision to this user via ApproverDelegation
+	// +optional
+	OnBehalfOf string `json:"onBehalfOf,omitempty"`
+}
+
+// EffectiveUser is the user the decision is counted for, i.e., the user delegated the decision if it is made by a delegate
+func (a *Approver) EffectiveUser() string {
+	if a.OnBehalfOf != "" {
+		return a.OnBehalfOf
+	}
+	return a.UserID
 }
 
 func (s *ApprovalStatus) GetCondition(t ConditionType) *Condition {
@@ -40,6 +141,16 @@ func (s *ApprovalStatus) GetCondition(t ConditionType) *Condition {
 	return nil
 }
 
+// IsFinal is true if the approving process already ended, i.e., any condition other than Waiting is True
+func (s *ApprovalStatus) IsFinal() bool {
+	for _, cond := range s.Conditions {
+		if cond.Type != ConditionWaiting && cond.IsTrue() {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *ApprovalStatus) GetApprover(u string) *Approver {
 	for i := range s.Approvers {
 		if s.Approvers[i].UserID == u {
@@ -66,6 +177,21 @@ func (s *ApprovalStatus) SetApprover(u string, d DecisionType) {
 
 }
 
-func (s *ApprovalStatus) IsApproversOverThreshold(thres int) bool {
-	return len(s.Approvers) >= thres
+// SetDecision replaces the decision counted for the same user, made by the user or by a delegate, or appends it
+func (s *ApprovalStatus) SetDecision(approver Approver) {
+	if old := s.GetEffectiveApprover(approver.EffectiveUser()); old != nil {
+		*old = approver
+		return
+	}
+	s.Approvers = append(s.Approvers, approver)
+}
+
+// GetEffectiveApprover returns the decision counted for the user, made by the user or by a delegate
+func (s *ApprovalStatus) GetEffectiveApprover(u string) *Approver {
+	for i := range s.Approvers {
+		if s.Approvers[i].EffectiveUser() == u {
+			return &s.Approvers[i]
+		}
+	}
+	return nil
 }
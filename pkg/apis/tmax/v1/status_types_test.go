@@ -0,0 +1,30 @@
+package v1
+
+import (
+	"testing"
+)
+
+func TestApprovalStatus_SetDecision(t *testing.T) {
+	status := &ApprovalStatus{Approvers: []Approver{
+		{UserID: "alice", Decision: DecisionApproved},
+		{UserID: "erin", Decision: DecisionApproved, OnBehalfOf: "bob"},
+	}}
+
+	// Replaces the decision of the same user
+	status.SetDecision(Approver{UserID: "alice", Decision: DecisionRejected})
+	if len(status.Approvers) != 2 || status.Approvers[0].Decision != DecisionRejected {
+		t.Fatalf("expected the decision of alice to be replaced, got %+v", status.Approvers)
+	}
+
+	// Replaces the decision counted for the user, made by the delegate
+	status.SetDecision(Approver{UserID: "bob", Decision: DecisionRejected})
+	if len(status.Approvers) != 2 || status.Approvers[1].UserID != "bob" || status.Approvers[1].OnBehalfOf != "" {
+		t.Fatalf("expected the decision counted for bob to be replaced, got %+v", status.Approvers)
+	}
+
+	// Appends the decision of a new user
+	status.SetDecision(Approver{UserID: "carol", Decision: DecisionApproved})
+	if len(status.Approvers) != 3 || status.GetApprover("carol") == nil {
+		t.Fatalf("expected the decision of carol to be appended, got %+v", status.Approvers)
+	}
+}
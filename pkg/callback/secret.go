@@ -0,0 +1,48 @@
+package callback
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"approval-operator/internal"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+const (
+	KeyField  = "key"
+	KeyLength = 32
+)
+
+// SecretName returns the name of the secret holding the callback credentials of the approval
+func SecretName(approvalName string) string {
+	return fmt.Sprintf("%s-callback", approvalName)
+}
+
+// EnsureSecret returns the callback secret of the approval, creating it if it does not exist.
+// The secret is owned by the approval, so it is garbage collected with the approval
+func EnsureSecret(ctx context.Context, c client.Client, approval *tmaxv1.Approval) (*corev1.Secret, error) {
+	key := types.NamespacedName{Name: SecretName(approval.Name), Namespace: approval.Namespace}
+	return internal.EnsureSecret(ctx, c, key, func() (*corev1.Secret, error) {
+		hmacKey := make([]byte, KeyLength)
+		if _, err := rand.Read(hmacKey); err != nil {
+			return nil, err
+		}
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(approval, tmaxv1.SchemeGroupVersion.WithKind("Approval")),
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				KeyField: hmacKey,
+			},
+		}, nil
+	})
+}
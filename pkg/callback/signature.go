@@ -0,0 +1,121 @@
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Approval-Signature"
+
+	// MaxClockSkew is the maximum difference between the signed time and the time of verification
+	MaxClockSkew = 5 * time.Minute
+)
+
+// Sign returns a signature header value for the body, signed at time t with the nonce.
+// The header value is in the form of 't=<unix time>,n=<nonce>,s=<hex encoded HMAC-SHA256>'
+func Sign(key, body []byte, t time.Time, nonce string) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,n=%s,s=%s", ts, nonce, hex.EncodeToString(mac(key, body, ts, nonce)))
+}
+
+// NewSignature signs the body at current time with a random nonce
+func NewSignature(key, body []byte) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return Sign(key, body, time.Now(), hex.EncodeToString(nonce)), nil
+}
+
+func mac(key, body []byte, ts, nonce string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(ts + "." + nonce + "."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Verifier verifies signatures made by Sign, rejecting stale or replayed ones
+type Verifier struct {
+	key []byte
+
+	lock sync.Mutex
+	// seen holds nonces verified within the clock skew window, to reject replayed messages
+	seen map[string]time.Time
+}
+
+func NewVerifier(key []byte) *Verifier {
+	return &Verifier{
+		key:  key,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Verify checks if the header value is a valid, fresh and not replayed signature of the body
+func (v *Verifier) Verify(header string, body []byte) error {
+	if header == "" {
+		return errors.New("message is not signed")
+	}
+
+	var ts, nonce, sig string
+	for _, field := range strings.Split(header, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("malformed signature field(%s)", field)
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "n":
+			nonce = kv[1]
+		case "s":
+			sig = kv[1]
+		}
+	}
+	if ts == "" || nonce == "" || sig == "" {
+		return errors.New("signature should have all of t, n and s fields")
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed signed time(%s)", ts)
+	}
+	signedTime := time.Unix(unix, 0)
+	now := time.Now()
+	if now.Sub(signedTime) > MaxClockSkew || signedTime.Sub(now) > MaxClockSkew {
+		return fmt.Errorf("signature is stale, signed at %s", signedTime.Format(time.RFC3339))
+	}
+
+	given, err := hex.DecodeString(sig)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	if !hmac.Equal(given, mac(v.key, body, ts, nonce)) {
+		return errors.New("signature mismatch")
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	// Forget nonces which cannot pass the staleness check anymore
+	for n, t := range v.seen {
+		if now.Sub(t) > 2*MaxClockSkew {
+			delete(v.seen, n)
+		}
+	}
+
+	if _, replayed := v.seen[nonce]; replayed {
+		return errors.New("message is replayed")
+	}
+	v.seen[nonce] = signedTime
+
+	return nil
+}
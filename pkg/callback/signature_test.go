@@ -0,0 +1,83 @@
+package callback
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	key := []byte("test-key")
+	body := []byte(`{"decision":"Approved"}`)
+
+	tc := map[string]struct {
+		header  func() string
+		body    []byte
+		wantErr bool
+	}{
+		"valid": {
+			header: func() string { return Sign(key, body, time.Now(), "nonce-valid") },
+			body:   body,
+		},
+		"unsigned": {
+			header:  func() string { return "" },
+			body:    body,
+			wantErr: true,
+		},
+		"malformed": {
+			header:  func() string { return "t=1,n=2" },
+			body:    body,
+			wantErr: true,
+		},
+		"wrongKey": {
+			header:  func() string { return Sign([]byte("other-key"), body, time.Now(), "nonce-wrong-key") },
+			body:    body,
+			wantErr: true,
+		},
+		"tampered": {
+			header:  func() string { return Sign(key, body, time.Now(), "nonce-tampered") },
+			body:    []byte(`{"decision":"Rejected"}`),
+			wantErr: true,
+		},
+		"stale": {
+			header:  func() string { return Sign(key, body, time.Now().Add(-2*MaxClockSkew), "nonce-stale") },
+			body:    body,
+			wantErr: true,
+		},
+		"future": {
+			header:  func() string { return Sign(key, body, time.Now().Add(2*MaxClockSkew), "nonce-future") },
+			body:    body,
+			wantErr: true,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			v := NewVerifier(key)
+			err := v.Verify(c.header(), c.body)
+			if c.wantErr && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !c.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyReplay(t *testing.T) {
+	key := []byte("test-key")
+	body := []byte(`{"decision":"Approved"}`)
+
+	header, err := NewSignature(key, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewVerifier(key)
+	if err := v.Verify(header, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(header, body); err == nil {
+		t.Fatal("replayed message is verified")
+	}
+}
@@ -0,0 +1,209 @@
+package callback
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"approval-operator/internal"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+const (
+	CASecretName = "approval-callback-ca"
+	CACertField  = "ca.crt"
+	CAKeyField   = "ca.key"
+
+	CertificateRequestType = "CERTIFICATE REQUEST"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+)
+
+// ServerName returns the DNS name in the certificate of the task, which is verified by the operator
+func ServerName(approval *tmaxv1.Approval) string {
+	return fmt.Sprintf("%s.%s.svc", approval.Name, approval.Namespace)
+}
+
+// CA is the certificate authority of the operator, issuing the certificates of the tasks
+type CA struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+	key     crypto.Signer
+}
+
+// EnsureCA returns the CA of the operator, creating it if it does not exist.
+// The CA is stored in a secret of the operator namespace, so that only the operator can issue certificates
+func EnsureCA(ctx context.Context, c client.Client) (*CA, error) {
+	ns, err := internal.Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	key := types.NamespacedName{Name: CASecretName, Namespace: ns}
+	secret, err := internal.EnsureSecret(ctx, c, key, func() (*corev1.Secret, error) {
+		certPEM, keyPEM, err := newCA()
+		if err != nil {
+			return nil, err
+		}
+		return &corev1.Secret{
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{CACertField: certPEM, CAKeyField: keyPEM},
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseCA(secret)
+}
+
+func newCA() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "approval-operator callback CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+func parseCA(secret *corev1.Secret) (*CA, error) {
+	certBlock, _ := pem.Decode(secret.Data[CACertField])
+	keyBlock, _ := pem.Decode(secret.Data[CAKeyField])
+	if certBlock == nil || keyBlock == nil {
+		return nil, fmt.Errorf("secret %s does not have a valid CA", secret.Name)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, CertPEM: secret.Data[CACertField], key: key}, nil
+}
+
+// ParseCertificateRequest parses the PEM encoded certificate request of the task and checks its signature
+func ParseCertificateRequest(csrPEM []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != CertificateRequestType {
+		return nil, errors.New("certificate request should be a PEM encoded CERTIFICATE REQUEST")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("signature of the certificate request is not valid: %s", err.Error())
+	}
+	return csr, nil
+}
+
+// Issue signs a server certificate for the task of the approval, with the public key of the certificate request.
+// The names requested are ignored, so that the certificate is valid only for the approval.
+// The private key is kept by the task, and never sent to the operator
+func (ca *CA) Issue(approval *tmaxv1.Approval, csrPEM []byte) ([]byte, error) {
+	csr, err := ParseCertificateRequest(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	notAfter := time.Now().AddDate(1, 0, 0)
+	if expiry := approval.ExpiryTime(); expiry != nil && expiry.After(time.Now()) {
+		notAfter = expiry.Add(24 * time.Hour)
+	}
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: ServerName(approval)},
+		DNSNames:     []string{ServerName(approval)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// ClientTLSConfig returns a TLS config which trusts only the certificate issued by the CA for the task of the approval
+func (ca *CA) ClientTLSConfig(approval *tmaxv1.Approval) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+
+	return &tls.Config{
+		RootCAs:    pool,
+		ServerName: ServerName(approval),
+	}
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// NewCertificateRequest generates the private key of the task and the certificate request for it, both PEM encoded
+func NewCertificateRequest(commonName string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: CertificateRequestType, Bytes: der}), nil
+}
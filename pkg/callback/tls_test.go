@@ -0,0 +1,145 @@
+package callback
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// newTaskServer serves https with the key of the task and the certificate issued by the CA
+func newTaskServer(t *testing.T, ca *CA, approval *tmaxv1.Approval) *httptest.Server {
+	key, csr, err := NewCertificateRequest("task")
+	if err != nil {
+		t.Fatal(err, "Error occurred at NewCertificateRequest")
+	}
+	certPEM, err := ca.Issue(approval, csr)
+	if err != nil {
+		t.Fatal(err, "Error occurred at Issue")
+	}
+	cert, err := tls.X509KeyPair(certPEM, key)
+	if err != nil {
+		t.Fatal(err, "Issued certificate is not valid")
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	return server
+}
+
+func trusts(tlsConfig *tls.Config, url string) bool {
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+func TestCA_ClientTLSConfig(t *testing.T) {
+	approval := &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-approval", Namespace: "default"},
+		Spec:       tmaxv1.ApprovalSpec{Scheme: tmaxv1.SchemeHTTPS},
+	}
+
+	c := fake.NewFakeClient()
+	ca, err := EnsureCA(context.TODO(), c)
+	if err != nil {
+		t.Fatal(err, "Error occurred at EnsureCA")
+	}
+
+	// The CA is created once, and reused
+	again, err := EnsureCA(context.TODO(), c)
+	if err != nil {
+		t.Fatal(err, "Error occurred at EnsureCA")
+	}
+	if !again.Cert.Equal(ca.Cert) {
+		t.Fatal("CA is created again")
+	}
+
+	server := newTaskServer(t, ca, approval)
+	defer server.Close()
+
+	// Operator should trust the task
+	if !trusts(ca.ClientTLSConfig(approval), server.URL) {
+		t.Fatal("Cannot verify the certificate of the task")
+	}
+
+	// Operator should not trust a certificate issued for another approval
+	other := approval.DeepCopy()
+	other.Name = "other-approval"
+	if trusts(ca.ClientTLSConfig(other), server.URL) {
+		t.Fatal("Certificate of another approval is trusted")
+	}
+
+	// Operator should not trust a certificate issued by another CA
+	certPEM, keyPEM, err := newCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCA, err := parseCA(caSecret(certPEM, keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherServer := newTaskServer(t, otherCA, approval)
+	defer otherServer.Close()
+	if trusts(ca.ClientTLSConfig(approval), otherServer.URL) {
+		t.Fatal("Certificate issued by another CA is trusted")
+	}
+}
+
+func TestCA_Issue(t *testing.T) {
+	approval := &tmaxv1.Approval{ObjectMeta: metav1.ObjectMeta{Name: "test-approval", Namespace: "default"}}
+
+	ca, err := EnsureCA(context.TODO(), fake.NewFakeClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Names requested are ignored
+	_, csr, err := NewCertificateRequest("kubernetes.default.svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Issue(approval, csr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != ServerName(approval) || cert.Subject.CommonName != ServerName(approval) {
+		t.Fatalf("expected the certificate only for %s, got %s, %v", ServerName(approval), cert.Subject.CommonName, cert.DNSNames)
+	}
+
+	// Invalid requests are rejected
+	for name, csr := range map[string][]byte{
+		"empty":    nil,
+		"notCSR":   ca.CertPEM,
+		"tampered": append([]byte{}, csr[:len(csr)-40]...),
+	} {
+		if _, err := ca.Issue(approval, csr); err == nil {
+			t.Fatalf("expected %s request to be rejected", name)
+		}
+	}
+}
+
+func caSecret(certPEM, keyPEM []byte) *corev1.Secret {
+	return &corev1.Secret{Data: map[string][]byte{CACertField: certPEM, CAKeyField: keyPEM}}
+}
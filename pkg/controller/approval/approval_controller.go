@@ -2,12 +2,16 @@ package approval
 
 import (
 	"approval-operator/pkg/apis"
+	"approval-operator/pkg/callback"
+	"approval-operator/pkg/notifier"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"net/http"
+	"reflect"
 	"time"
 
 	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
@@ -15,6 +19,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -39,7 +44,13 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileApproval{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileApproval{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		maxRetry: MaxRetry(),
+		backoff:  RetryBackoff(),
+		recorder: mgr.GetEventRecorderFor("approval-controller"),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -56,6 +67,14 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for termination of the pods requested approvals
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: &ownedApprovalsMapper{client: mgr.GetClient()},
+	}, podTerminationPredicate())
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -68,6 +87,13 @@ type ReconcileApproval struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+
+	// Retry policy for sending decisions to the task
+	maxRetry int32
+	backoff  time.Duration
+
+	// recorder emits events of the approvals, e.g., escalations
+	recorder record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a Approval object and makes changes based on the state read
@@ -98,71 +124,212 @@ func (r *ReconcileApproval) Reconcile(request reconcile.Request) (reconcile.Resu
 	}
 
 	if len(instance.Status.Conditions) == 0 {
+		reqLogger.Info("Approval initialize. Create callback secret.")
+		if _, err = callback.EnsureSecret(context.TODO(), r.client, instance); err != nil {
+			reqLogger.Error(err, "Failed to create callback secret")
+			return reconcile.Result{}, err
+		}
+
+		if len(instance.Spec.Stages) > 0 {
+			reqLogger.Info("Approval initialize. Start the first stage.")
+			instance.Status.CurrentStage = 0
+			instance.Status.Stages = []tmaxv1.StageStatus{newStageStatus(instance.Spec.Stages[0])}
+		}
+
 		reqLogger.Info("Approval initialize. Set Waiting status.")
-		if err = r.setStatus(instance, tmaxv1.ConditionWaiting); err != nil {
+		if err = r.setStatus(instance, tmaxv1.ConditionWaiting, "", ""); err != nil {
 			reqLogger.Error(err, "Failed to set Waiting status")
 			return reconcile.Result{}, err
 		}
+
+		r.notify(instance, notifier.EventCreated, instance.ActivePolicy().Users, "")
 		return reconcile.Result{}, nil
 	}
 
 	// If condition is not "Waiting" and Status true, end this function
-	for _, cond := range instance.Status.Conditions {
-		if cond.Type != tmaxv1.ConditionWaiting && cond.Status == corev1.ConditionTrue {
-			reqLogger.Info("The approving process already ended")
-			return reconcile.Result{}, nil
+	if instance.Status.IsFinal() {
+		reqLogger.Info("The approving process already ended")
+		return reconcile.Result{}, nil
+	}
+
+	// If the pod requested the approval is terminated, cancel the approval without sending any decision
+	if reason, err := r.podTermination(instance); err != nil {
+		reqLogger.Error(err, "Failed to get the requesting pod")
+		return reconcile.Result{}, err
+	} else if reason != "" {
+		reqLogger.Info("Requesting pod is terminated. Cancel the approval.")
+		if err := r.setStatus(instance, tmaxv1.ConditionCancelled, "PodTerminated", reason); err != nil {
+			reqLogger.Error(err, "Failed to set status")
+			return reconcile.Result{}, err
 		}
+		return reconcile.Result{}, nil
 	}
 
-	// If there are any rejection, send reject message
-	for _, appr := range instance.Status.Approvers {
-		if appr.Decision == tmaxv1.DecisionRejected {
-			if err := r.sendMsgToTask(instance, tmaxv1.DecisionRejected); err != nil {
-				reqLogger.Error(err, "Failed to send reject msg to Task")
-				// instance.Status.Condition create failed condition and reason
-				return reconcile.Result{}, err
-			}
+	// Record the votes cast or withdrawn and keep the tally of the stage in progress up to date.
+	// Decisions below are re-evaluated with the current votes, so that withdrawn ones are not counted
+	policy := instance.ActivePolicy()
+	tally := policy.Tally(instance.Status.Approvers)
+	if recordHistory(instance, time.Now()) || !reflect.DeepEqual(instance.Status.Tally, &tally) {
+		instance.Status.Tally = &tally
+		if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+			reqLogger.Error(err, "Failed to update history and tally")
+			return reconcile.Result{}, err
+		}
+	}
 
-			// if succeed to send msg, change status
-			if err := r.setStatus(instance, tmaxv1.ConditionRejected); err != nil {
-				reqLogger.Error(err, "Failed to set status")
+	// If the rejections satisfy the rejection rule, send reject message. Rejection in any stage rejects the whole approval
+	if policy.IsRejected(instance.Status.Approvers) {
+		completeStage(instance, tmaxv1.ConditionRejected)
+		return r.decide(instance, tmaxv1.DecisionRejected, tmaxv1.ConditionRejected, "", "")
+	}
+
+	// If the approvals satisfy the thresholds and the quorum,
+	if policy.Evaluate(instance.Status.Approvers) {
+		// Proceed to the next stage, if the stage in progress is not the last one
+		if !instance.IsLastStage() {
+			reqLogger.Info("Stage is approved. Proceed to the next stage.")
+			if err := r.nextStage(instance); err != nil {
+				reqLogger.Error(err, "Failed to proceed to the next stage")
 				return reconcile.Result{}, err
 			}
-
-			// rejection success
+			r.notify(instance, notifier.EventCreated, instance.ActivePolicy().Users,
+				fmt.Sprintf("stage %s is started", instance.Spec.Stages[instance.Status.CurrentStage].Name))
 			return reconcile.Result{}, nil
 		}
+
+		completeStage(instance, tmaxv1.ConditionApproved)
+		return r.decide(instance, tmaxv1.DecisionApproved, tmaxv1.ConditionApproved, "", "")
 	}
 
-	// If any of approvals make rejection and the number of approvals is over the threshold,
-	if instance.Status.IsApproversOverThreshold(int(instance.Spec.Threshold)) {
-		if err := r.sendMsgToTask(instance, tmaxv1.DecisionApproved); err != nil {
-			reqLogger.Error(err, "Failed to send approve msg to Task")
-			//instance.Status.Conditions create failed condition and reason
-			return reconcile.Result{}, err
+	// Let the task know the changes requested, while the approval is in progress
+	if err := r.deliverChangeRequests(instance); err != nil {
+		reqLogger.Error(err, "Failed to record change requests delivered")
+		return reconcile.Result{}, err
+	}
+
+	// Widen the approvers, if the stage in progress is stuck
+	if err := r.escalate(instance); err != nil {
+		reqLogger.Error(err, "Failed to escalate")
+		return reconcile.Result{}, err
+	}
+
+	// Remind the users who have not decided yet, if the interval has passed
+	if err := r.remind(instance); err != nil {
+		reqLogger.Error(err, "Failed to remind approvers")
+		return reconcile.Result{}, err
+	}
+
+	// If the deadline has passed, send the default decision. Otherwise, requeue at the deadline, the next escalation or the next reminder
+	result := reconcile.Result{}
+	if expiry := instance.ExpiryTime(); expiry != nil {
+		remaining := time.Until(*expiry)
+		if remaining <= 0 {
+			decision := instance.ExpiryDecision()
+			msg := fmt.Sprintf("approval expired at %s, default decision %s is sent", expiry.Format(time.RFC3339), decision)
+			return r.decide(instance, decision, tmaxv1.ConditionExpired, "DeadlineExceeded", msg)
+		}
+		result.RequeueAfter = remaining
+	}
+	for _, next := range []*time.Time{instance.NextEscalationTime(), instance.NextReminderTime()} {
+		if next == nil {
+			continue
+		}
+		if remaining := time.Until(*next); result.RequeueAfter == 0 || remaining < result.RequeueAfter {
+			result.RequeueAfter = remaining
 		}
+	}
 
-		// if succeed to send msg, change status
-		if err := r.setStatus(instance, tmaxv1.ConditionApproved); err != nil {
-			reqLogger.Error(err, "Failed to set status")
-			return reconcile.Result{}, err
+	return result, nil
+}
+
+// decide sends the decision to the task and, if succeeded, sets the final condition.
+// If sending fails, it is retried with exponential backoff until the retry limit is reached
+func (r *ReconcileApproval) decide(cr *tmaxv1.Approval, dt tmaxv1.DecisionType, ct tmaxv1.ConditionType, reason, message string) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", cr.Namespace, "Request.Name", cr.Name)
+
+	// Wait for the backoff of the previous failure to pass
+	if cr.Status.Retry > 0 && cr.Status.LastRetryTime != nil {
+		if remaining := time.Until(cr.Status.LastRetryTime.Add(r.retryBackoff(cr.Status.Retry))); remaining > 0 {
+			return reconcile.Result{RequeueAfter: remaining}, nil
 		}
+	}
 
-		// approved success
-		return reconcile.Result{}, nil
+	resp, err := r.sendMsgToTask(cr, apis.ApprovedMessage{Decision: dt, Comments: comments(cr, "")})
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("Failed to send %s msg to Task", dt))
+		return r.setRetry(cr, err)
+	}
+	cr.Status.Response = resp
+
+	// if succeed to send msg, change status
+	if err := r.setStatus(cr, ct, reason, message); err != nil {
+		reqLogger.Error(err, "Failed to set status")
+		return reconcile.Result{}, err
 	}
 
+	msg := fmt.Sprintf("decision %s is made", dt)
+	if message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, message)
+	}
+	r.notify(cr, notifier.EventDecided, cr.ActivePolicy().Users, msg)
+
 	return reconcile.Result{}, nil
 }
 
-func (r *ReconcileApproval) setStatus(cr *tmaxv1.Approval, ct tmaxv1.ConditionType) error {
+// setRetry records the failure of sending a message to the task.
+// If the retry limit is reached, the approval is marked as Failed
+func (r *ReconcileApproval) setRetry(cr *tmaxv1.Approval, sendErr error) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", cr.Namespace, "Request.Name", cr.Name)
+
+	now := metav1.NewTime(time.Now())
+	cr.Status.Retry++
+	cr.Status.LastRetryTime = &now
+
+	if cr.Status.Retry >= r.maxRetry {
+		msg := fmt.Sprintf("failed to send decision to the task %d times, last error: %s", cr.Status.Retry, sendErr.Error())
+		if err := r.setStatus(cr, tmaxv1.ConditionFailed, "SendFailed", msg); err != nil {
+			reqLogger.Error(err, "Failed to set Failed status")
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
+
+	// Record the last error to the Waiting condition
+	for i := range cr.Status.Conditions {
+		if cr.Status.Conditions[i].Type == tmaxv1.ConditionWaiting {
+			cr.Status.Conditions[i].Reason = "SendFailed"
+			cr.Status.Conditions[i].Message = fmt.Sprintf("retry %d/%d, last error: %s", cr.Status.Retry, r.maxRetry, sendErr.Error())
+		}
+	}
+
+	if err := r.client.Status().Update(context.TODO(), cr); err != nil {
+		reqLogger.Error(err, "Unknown error updating status")
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{RequeueAfter: r.retryBackoff(cr.Status.Retry)}, nil
+}
+
+// retryBackoff returns the backoff duration after the n-th failure, doubling each time up to MaxRetryBackoff
+func (r *ReconcileApproval) retryBackoff(n int32) time.Duration {
+	backoff := r.backoff
+	for i := int32(1); i < n && backoff < MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxRetryBackoff {
+		backoff = MaxRetryBackoff
+	}
+	return backoff
+}
+
+func (r *ReconcileApproval) setStatus(cr *tmaxv1.Approval, ct tmaxv1.ConditionType, reason, message string) error {
 	reqLogger := log.WithValues("Request.Namespace", cr.Namespace, "Request.Name", cr.Name)
 	newCondition := tmaxv1.Condition{
 		Type:               ct,
 		Status:             corev1.ConditionTrue,
 		LastTransitionTime: metav1.NewTime(time.Now()),
-		Reason:             "",
-		Message:            "",
+		Reason:             reason,
+		Message:            message,
 	}
 
 	if len(cr.Status.Conditions) != 0 {
@@ -178,28 +345,71 @@ func (r *ReconcileApproval) setStatus(cr *tmaxv1.Approval, ct tmaxv1.ConditionTy
 	return nil
 }
 
-func (r *ReconcileApproval) sendMsgToTask(cr *tmaxv1.Approval, dt tmaxv1.DecisionType) error {
-
-	data := apis.ApprovedMessage{
-		Decision: dt,
-	}
+// sendMsgToTask sends the message to the task and returns the response message of the task.
+// It is regarded as failed unless the task replies with 2xx status code and the same decision
+func (r *ReconcileApproval) sendMsgToTask(cr *tmaxv1.Approval, data apis.ApprovedMessage) (string, error) {
+	dt := data.Decision
 	payloadBytes, err := json.Marshal(data)
 	if err != nil {
-		return err
+		return "", err
 	}
 	body := bytes.NewReader(payloadBytes)
 
-	req, err := http.NewRequest("PUT", fmt.Sprint("http://", cr.Spec.PodIP, ":", cr.Spec.Port, cr.Spec.AccessPath), body)
+	// Sign the message with the key shared with the task
+	secret, err := callback.EnsureSecret(context.TODO(), r.client, cr)
 	if err != nil {
-		return err
+		return "", err
+	}
+	signature, err := callback.NewSignature(secret.Data[callback.KeyField], payloadBytes)
+	if err != nil {
+		return "", err
+	}
+
+	// Verify the certificate of the task against the CA of the operator, if https is used
+	scheme := tmaxv1.SchemeHTTP
+	httpClient := &http.Client{Timeout: CallbackTimeout}
+	if cr.IsTLS() {
+		ca, err := callback.EnsureCA(context.TODO(), r.client)
+		if err != nil {
+			return "", err
+		}
+		// The transport is used only once, so its connections should not be left open
+		transport := &http.Transport{TLSClientConfig: ca.ClientTLSConfig(cr)}
+		defer transport.CloseIdleConnections()
+		scheme = tmaxv1.SchemeHTTPS
+		httpClient.Transport = transport
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprint(scheme, "://", cr.Spec.PodIP, ":", cr.Spec.Port, cr.Spec.AccessPath), body)
+	if err != nil {
+		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(callback.SignatureHeader, signature)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	return nil
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("task replied with status %d: %s", resp.StatusCode, string(respBytes))
+	}
+
+	reply := apis.ApprovedMessage{}
+	if err := json.Unmarshal(respBytes, &reply); err != nil {
+		return "", fmt.Errorf("cannot decode reply of the task: %s", err.Error())
+	}
+
+	if reply.Decision != dt {
+		return "", fmt.Errorf("task replied with decision %s, expected %s: %s", reply.Decision, dt, reply.Response)
+	}
+
+	return reply.Response, nil
 }
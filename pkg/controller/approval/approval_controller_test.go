@@ -0,0 +1,467 @@
+package approval
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"approval-operator/pkg/apis"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/callback"
+)
+
+// taskServer stands in for the watcher of the task, replying to the decisions with reply
+type taskServer struct {
+	*httptest.Server
+	received []apis.ApprovedMessage
+	reply    func(m apis.ApprovedMessage) (int, apis.ApprovedMessage)
+}
+
+func newTaskServer(t *testing.T) *taskServer {
+	s := newUnstartedTaskServer(t)
+	s.Start()
+	return s
+}
+
+func newUnstartedTaskServer(t *testing.T) *taskServer {
+	s := &taskServer{
+		// Echo the decision by default, as the watcher does
+		reply: func(m apis.ApprovedMessage) (int, apis.ApprovedMessage) {
+			return http.StatusOK, apis.ApprovedMessage{Decision: m.Decision, Response: "accepted"}
+		},
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := apis.ApprovedMessage{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Error(err)
+		}
+		s.received = append(s.received, m)
+
+		code, reply := s.reply(m)
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(reply); err != nil {
+			t.Error(err)
+		}
+	}))
+	return s
+}
+
+// newTestApproval returns a waiting approval whose task is served by the server
+func newTestApproval(t *testing.T, server *taskServer) *tmaxv1.Approval {
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test",
+			Namespace:         "default",
+			UID:               "approval-uid",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Minute)),
+		},
+		Spec: tmaxv1.ApprovalSpec{
+			PodIP:      host,
+			Port:       int32(p),
+			AccessPath: "/",
+			ApproverPolicy: tmaxv1.ApproverPolicy{
+				Threshold: 1,
+				Users:     map[string]string{"alice": "alice@tmax.co.kr", "bob": "bob@tmax.co.kr"},
+			},
+		},
+		Status: tmaxv1.ApprovalStatus{
+			Conditions: tmaxv1.Conditions{{Type: tmaxv1.ConditionWaiting, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+// newTestReconciler returns a reconciler with a fake client holding the objects
+func newTestReconciler(t *testing.T, objs ...runtime.Object) *ReconcileApproval {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmaxv1.SchemeBuilder.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return &ReconcileApproval{
+		client:   fake.NewFakeClientWithScheme(s, objs...),
+		scheme:   s,
+		maxRetry: DefaultMaxRetry,
+		backoff:  time.Millisecond,
+		recorder: record.NewFakeRecorder(100),
+	}
+}
+
+// reconcileApproval reconciles the approval and returns the result and the approval after reconciliation
+func reconcileApproval(t *testing.T, r *ReconcileApproval, cr *tmaxv1.Approval) (reconcile.Result, *tmaxv1.Approval) {
+	key := types.NamespacedName{Name: cr.Name, Namespace: cr.Namespace}
+	result, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance := &tmaxv1.Approval{}
+	if err := r.client.Get(context.TODO(), key, instance); err != nil {
+		t.Fatal(err)
+	}
+	return result, instance
+}
+
+func TestReconcile_Expiry(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Second))
+
+	tc := map[string]struct {
+		defaultDecision tmaxv1.DecisionType
+		expected        tmaxv1.DecisionType
+	}{
+		"rejectByDefault": {expected: tmaxv1.DecisionRejected},
+		"approve":         {defaultDecision: tmaxv1.DecisionApproved, expected: tmaxv1.DecisionApproved},
+		"reject":          {defaultDecision: tmaxv1.DecisionRejected, expected: tmaxv1.DecisionRejected},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			server := newTaskServer(t)
+			defer server.Close()
+
+			cr := newTestApproval(t, server)
+			cr.Spec.Deadline = &past
+			cr.Spec.DefaultDecision = c.defaultDecision
+
+			_, instance := reconcileApproval(t, newTestReconciler(t, cr), cr)
+
+			if len(server.received) != 1 || server.received[0].Decision != c.expected {
+				t.Fatalf("expected %s to be sent, got %+v", c.expected, server.received)
+			}
+			cond := instance.Status.GetCondition(tmaxv1.ConditionExpired)
+			if cond == nil || !cond.IsTrue() || cond.Reason != "DeadlineExceeded" {
+				t.Fatalf("expected Expired condition, got %+v", instance.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestReconcile_RequeueAtDeadline(t *testing.T) {
+	server := newTaskServer(t)
+	defer server.Close()
+
+	cr := newTestApproval(t, server)
+	cr.Spec.Timeout = &metav1.Duration{Duration: time.Hour}
+
+	result, instance := reconcileApproval(t, newTestReconciler(t, cr), cr)
+
+	if len(server.received) != 0 {
+		t.Fatalf("expected no decision to be sent, got %+v", server.received)
+	}
+	if instance.Status.IsFinal() {
+		t.Fatalf("expected to be waiting, got %+v", instance.Status.Conditions)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour {
+		t.Fatalf("expected to be requeued before the deadline, got %s", result.RequeueAfter)
+	}
+}
+
+func TestReconcile_RetryUntilFailed(t *testing.T) {
+	server := newTaskServer(t)
+	defer server.Close()
+	server.reply = func(m apis.ApprovedMessage) (int, apis.ApprovedMessage) {
+		return http.StatusInternalServerError, apis.ApprovedMessage{}
+	}
+
+	cr := newTestApproval(t, server)
+	cr.Status.Approvers = []tmaxv1.Approver{{UserID: "alice", Decision: tmaxv1.DecisionApproved, ApprovedTime: metav1.Now()}}
+
+	r := newTestReconciler(t, cr)
+	r.maxRetry = 3
+
+	for i := int32(1); i < r.maxRetry; i++ {
+		result, instance := reconcileApproval(t, r, cr)
+		if instance.Status.Retry != i || instance.Status.LastRetryTime == nil {
+			t.Fatalf("expected retry %d to be recorded, got %d", i, instance.Status.Retry)
+		}
+		if cond := instance.Status.GetCondition(tmaxv1.ConditionWaiting); cond == nil || cond.Reason != "SendFailed" {
+			t.Fatalf("expected the error to be recorded to Waiting condition, got %+v", instance.Status.Conditions)
+		}
+		if result.RequeueAfter != r.retryBackoff(i) {
+			t.Fatalf("expected to be requeued after %s, got %s", r.retryBackoff(i), result.RequeueAfter)
+		}
+		time.Sleep(result.RequeueAfter)
+	}
+
+	_, instance := reconcileApproval(t, r, cr)
+	cond := instance.Status.GetCondition(tmaxv1.ConditionFailed)
+	if cond == nil || !cond.IsTrue() || cond.Reason != "SendFailed" {
+		t.Fatalf("expected Failed condition, got %+v", instance.Status.Conditions)
+	}
+	if len(server.received) != int(r.maxRetry) {
+		t.Fatalf("expected %d attempts, got %d", r.maxRetry, len(server.received))
+	}
+
+	// Nothing is sent after failed
+	reconcileApproval(t, r, cr)
+	if len(server.received) != int(r.maxRetry) {
+		t.Fatalf("expected no more attempts after failed, got %d", len(server.received))
+	}
+}
+
+func TestReconcile_RetryBackoff(t *testing.T) {
+	r := &ReconcileApproval{backoff: time.Second}
+
+	tc := map[string]struct {
+		n        int32
+		expected time.Duration
+	}{
+		"first":  {n: 1, expected: time.Second},
+		"second": {n: 2, expected: 2 * time.Second},
+		"third":  {n: 3, expected: 4 * time.Second},
+		"capped": {n: 20, expected: MaxRetryBackoff},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			if b := r.retryBackoff(c.n); b != c.expected {
+				t.Fatalf("expected %s, got %s", c.expected, b)
+			}
+		})
+	}
+}
+
+func TestReconcile_TaskReply(t *testing.T) {
+	tc := map[string]struct {
+		code     int
+		reply    apis.ApprovedMessage
+		sent     bool
+		response string
+	}{
+		"accepted": {
+			code:     http.StatusOK,
+			reply:    apis.ApprovedMessage{Decision: tmaxv1.DecisionApproved, Response: "deploying"},
+			sent:     true,
+			response: "deploying",
+		},
+		"serverError": {
+			code:  http.StatusInternalServerError,
+			reply: apis.ApprovedMessage{Decision: tmaxv1.DecisionApproved},
+		},
+		"unauthorized": {
+			code:  http.StatusUnauthorized,
+			reply: apis.ApprovedMessage{Decision: tmaxv1.DecisionApproved},
+		},
+		"unknownDecision": {
+			code:  http.StatusOK,
+			reply: apis.ApprovedMessage{Decision: tmaxv1.DecisionUnknown, Response: "Decision Unknown: "},
+		},
+		"otherDecision": {
+			code:  http.StatusOK,
+			reply: apis.ApprovedMessage{Decision: tmaxv1.DecisionRejected},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			server := newTaskServer(t)
+			defer server.Close()
+			server.reply = func(apis.ApprovedMessage) (int, apis.ApprovedMessage) {
+				return c.code, c.reply
+			}
+
+			cr := newTestApproval(t, server)
+			cr.Status.Approvers = []tmaxv1.Approver{{UserID: "alice", Decision: tmaxv1.DecisionApproved, ApprovedTime: metav1.Now()}}
+
+			_, instance := reconcileApproval(t, newTestReconciler(t, cr), cr)
+
+			if len(server.received) != 1 || server.received[0].Decision != tmaxv1.DecisionApproved {
+				t.Fatalf("expected Approved to be sent, got %+v", server.received)
+			}
+			cond := instance.Status.GetCondition(tmaxv1.ConditionApproved)
+			if c.sent {
+				if cond == nil || !cond.IsTrue() || instance.Status.Response != c.response {
+					t.Fatalf("expected Approved with response %q, got %+v, %q", c.response, instance.Status.Conditions, instance.Status.Response)
+				}
+				return
+			}
+			if cond != nil || instance.Status.Retry != 1 {
+				t.Fatalf("expected a failed delivery to be retried, got %+v, retry %d", instance.Status.Conditions, instance.Status.Retry)
+			}
+		})
+	}
+}
+
+func TestReconcile_HTTPS(t *testing.T) {
+	server := newUnstartedTaskServer(t)
+	cr := newTestApproval(t, server)
+	cr.Spec.Scheme = tmaxv1.SchemeHTTPS
+	cr.Status.Approvers = []tmaxv1.Approver{{UserID: "alice", Decision: tmaxv1.DecisionApproved, ApprovedTime: metav1.Now()}}
+
+	r := newTestReconciler(t, cr)
+
+	// The task serves the certificate issued by the CA of the operator, with its own key
+	ca, err := callback.EnsureCA(context.TODO(), r.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, csr, err := callback.NewCertificateRequest("task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Issue(cr, csr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	_, instance := reconcileApproval(t, r, cr)
+
+	if len(server.received) != 1 {
+		t.Fatalf("expected the decision to be sent over https, got %+v", server.received)
+	}
+	if cond := instance.Status.GetCondition(tmaxv1.ConditionApproved); cond == nil || !cond.IsTrue() {
+		t.Fatalf("expected Approved condition, got %+v", instance.Status.Conditions)
+	}
+}
+
+// newTestPod returns the running pod requested the approval
+func newTestPod(cr *tmaxv1.Approval) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "task", Namespace: cr.Namespace, UID: "pod-uid"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: cr.Spec.PodIP},
+	}
+}
+
+func TestReconcile_PodTermination(t *testing.T) {
+	now := metav1.Now()
+
+	tc := map[string]struct {
+		modify    func(pod *corev1.Pod)
+		deleted   bool
+		cancelled bool
+	}{
+		"running":   {modify: func(pod *corev1.Pod) {}, cancelled: false},
+		"deleted":   {deleted: true, cancelled: true},
+		"replaced":  {modify: func(pod *corev1.Pod) { pod.UID = "another-uid" }, cancelled: true},
+		"deleting":  {modify: func(pod *corev1.Pod) { pod.DeletionTimestamp = &now }, cancelled: true},
+		"succeeded": {modify: func(pod *corev1.Pod) { pod.Status.Phase = corev1.PodSucceeded }, cancelled: true},
+		"failed":    {modify: func(pod *corev1.Pod) { pod.Status.Phase = corev1.PodFailed }, cancelled: true},
+		"ipChanged": {modify: func(pod *corev1.Pod) { pod.Status.PodIP = "10.0.0.2" }, cancelled: true},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			server := newTaskServer(t)
+			defer server.Close()
+
+			cr := newTestApproval(t, server)
+			cr.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "task", UID: "pod-uid"}}
+			objs := []runtime.Object{cr}
+			if !c.deleted {
+				pod := newTestPod(cr)
+				c.modify(pod)
+				objs = append(objs, pod)
+			}
+			r := newTestReconciler(t, objs...)
+
+			_, instance := reconcileApproval(t, r, cr)
+
+			cond := instance.Status.GetCondition(tmaxv1.ConditionCancelled)
+			if c.cancelled != (cond != nil && cond.IsTrue()) {
+				t.Fatalf("expected cancelled to be %t, got %+v", c.cancelled, instance.Status.Conditions)
+			}
+			if c.cancelled && (cond.Reason != "PodTerminated" || cond.Message == "") {
+				t.Fatalf("expected the termination of the pod to be recorded, got %+v", cond)
+			}
+			if len(server.received) != 0 {
+				t.Fatalf("expected no decision to be sent, got %+v", server.received)
+			}
+			if ref := RequestingPod(instance); ref == nil || ref.UID != "pod-uid" {
+				t.Fatalf("expected the owner reference to the pod to be kept, got %+v", instance.OwnerReferences)
+			}
+		})
+	}
+}
+
+func TestOwnedApprovalsMapper(t *testing.T) {
+	server := newTaskServer(t)
+	defer server.Close()
+
+	owned := newTestApproval(t, server)
+	owned.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "task", UID: "pod-uid"}}
+	other := newTestApproval(t, server)
+	other.Name = "other"
+	other.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "task", UID: "another-uid"}}
+	unowned := newTestApproval(t, server)
+	unowned.Name = "unowned"
+	pod := newTestPod(owned)
+
+	r := newTestReconciler(t, owned, other, unowned, pod)
+	m := &ownedApprovalsMapper{client: r.client}
+
+	requests := m.Map(handler.MapObject{Meta: pod, Object: pod})
+	if len(requests) != 1 || requests[0].Name != owned.Name || requests[0].Namespace != owned.Namespace {
+		t.Fatalf("expected only the approval owned by the pod, got %+v", requests)
+	}
+}
+
+func TestPodTerminationPredicate(t *testing.T) {
+	now := metav1.Now()
+	running := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "task", Namespace: "default"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+	}
+
+	tc := map[string]struct {
+		modify   func(pod *corev1.Pod)
+		expected bool
+	}{
+		"stillRunning": {modify: func(pod *corev1.Pod) { pod.Labels = map[string]string{"app": "task"} }, expected: false},
+		"phase":        {modify: func(pod *corev1.Pod) { pod.Status.Phase = corev1.PodSucceeded }, expected: true},
+		"ip":           {modify: func(pod *corev1.Pod) { pod.Status.PodIP = "10.0.0.2" }, expected: true},
+		"deleting":     {modify: func(pod *corev1.Pod) { pod.DeletionTimestamp = &now }, expected: true},
+	}
+
+	p := podTerminationPredicate()
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			pod := running.DeepCopy()
+			c.modify(pod)
+			e := event.UpdateEvent{MetaOld: running, ObjectOld: running, MetaNew: pod, ObjectNew: pod}
+			if p.Update(e) != c.expected {
+				t.Fatalf("expected %t for the update", c.expected)
+			}
+		})
+	}
+
+	if p.Create(event.CreateEvent{Meta: running, Object: running}) {
+		t.Fatal("expected creation of a pod to be skipped")
+	}
+	if !p.Delete(event.DeleteEvent{Meta: running, Object: running}) {
+		t.Fatal("expected deletion of a pod to be passed")
+	}
+}
@@ -0,0 +1,68 @@
+package approval
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"approval-operator/pkg/apis"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// deliverChangeRequests sends the change requests, which are not delivered yet, to the task.
+// A change request is delivered again if the user requests changes again.
+// A failed delivery is reported as an event and left undelivered, to be retried at the next reconciliation,
+// so that it does not hold up the rest of the approving process
+func (r *ReconcileApproval) deliverChangeRequests(cr *tmaxv1.Approval) error {
+	delivered := false
+	for _, a := range cr.Status.Approvers {
+		if a.Decision != tmaxv1.DecisionRequestChanges {
+			continue
+		}
+		if t, exist := cr.Status.ChangeRequestsDelivered[a.EffectiveUser()]; exist && t.Equal(&a.ApprovedTime) {
+			continue
+		}
+
+		msg := apis.ApprovedMessage{Decision: tmaxv1.DecisionRequestChanges, UserID: a.UserID, Comments: comments(cr, a.EffectiveUser())}
+		if _, err := r.sendMsgToTask(cr, msg); err != nil {
+			log.Error(err, "Failed to deliver change request to Task", "Request.Namespace", cr.Namespace, "Request.Name", cr.Name, "User", a.EffectiveUser())
+			r.recorder.Event(cr, corev1.EventTypeWarning, "ChangeRequestFailed", fmt.Sprintf("failed to deliver change request of %s: %s", a.EffectiveUser(), err.Error()))
+			continue
+		}
+
+		if cr.Status.ChangeRequestsDelivered == nil {
+			cr.Status.ChangeRequestsDelivered = map[string]metav1.Time{}
+		}
+		cr.Status.ChangeRequestsDelivered[a.EffectiveUser()] = a.ApprovedTime
+		delivered = true
+	}
+
+	if !delivered {
+		return nil
+	}
+	return r.client.Status().Update(context.TODO(), cr)
+}
+
+// comments collects the comments of the approvers in all stages, to be forwarded to the task.
+// If user is not empty, only the comment of the user in the stage in progress is collected
+func comments(cr *tmaxv1.Approval, user string) []apis.Comment {
+	var approvers []tmaxv1.Approver
+	if user == "" {
+		// Decisions of the completed stages are in the stage statuses
+		for i := 0; i < int(cr.Status.CurrentStage) && i < len(cr.Status.Stages); i++ {
+			approvers = append(approvers, cr.Status.Stages[i].Approvers...)
+		}
+	}
+	approvers = append(approvers, cr.Status.Approvers...)
+
+	var result []apis.Comment
+	for _, a := range approvers {
+		if (user != "" && a.EffectiveUser() != user) || (a.Reason == "" && a.Comment == "") {
+			continue
+		}
+		result = append(result, apis.Comment{UserID: a.UserID, OnBehalfOf: a.OnBehalfOf, Decision: a.Decision, Reason: a.Reason, Comment: a.Comment})
+	}
+	return result
+}
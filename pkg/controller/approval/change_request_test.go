@@ -0,0 +1,81 @@
+package approval
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"approval-operator/pkg/apis"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func TestReconcile_ChangeRequests(t *testing.T) {
+	requested := vote("alice", tmaxv1.DecisionRequestChanges)
+	requested.Comment = "fix the image tag"
+
+	tc := map[string]struct {
+		delivered map[string]metav1.Time
+		status    int
+		sent      int
+		recorded  bool
+		event     string
+	}{
+		"delivered":        {status: http.StatusOK, sent: 1, recorded: true},
+		"failed":           {status: http.StatusInternalServerError, sent: 1, recorded: false, event: "ChangeRequestFailed"},
+		"alreadyDelivered": {delivered: map[string]metav1.Time{"alice": requested.ApprovedTime}, status: http.StatusOK, sent: 0, recorded: true},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			server := newTaskServer(t)
+			defer server.Close()
+			server.reply = func(m apis.ApprovedMessage) (int, apis.ApprovedMessage) {
+				return c.status, apis.ApprovedMessage{Decision: m.Decision}
+			}
+
+			// The stage is stuck, so that it is escalated after the change requests
+			cr := newTestApproval(t, server)
+			cr.Spec.Escalations = []tmaxv1.EscalationStep{
+				{After: metav1.Duration{Duration: 30 * time.Second}, Users: map[string]string{"carol": "carol@tmax.co.kr"}},
+			}
+			cr.Status.Approvers = []tmaxv1.Approver{requested}
+			cr.Status.ChangeRequestsDelivered = c.delivered
+			r := newTestReconciler(t, cr)
+
+			_, instance := reconcileApproval(t, r, cr)
+
+			if len(server.received) != c.sent {
+				t.Fatalf("expected %d change requests to be sent, got %+v", c.sent, server.received)
+			}
+			if c.sent > 0 {
+				m := server.received[0]
+				if m.Decision != tmaxv1.DecisionRequestChanges || m.UserID != "alice" || len(m.Comments) != 1 || m.Comments[0].Comment != requested.Comment {
+					t.Fatalf("unexpected change request: %+v", m)
+				}
+			}
+			if _, recorded := instance.Status.ChangeRequestsDelivered["alice"]; recorded != c.recorded {
+				t.Fatalf("expected the delivery recorded to be %t, got %+v", c.recorded, instance.Status.ChangeRequestsDelivered)
+			}
+			if instance.Status.EscalationLevel != 1 {
+				t.Fatalf("expected the approval to be escalated regardless of the delivery, got level %d", instance.Status.EscalationLevel)
+			}
+			if instance.Status.IsFinal() {
+				t.Fatalf("expected the approval to be in progress, got %+v", instance.Status.Conditions)
+			}
+
+			found := false
+			for len(r.recorder.(*record.FakeRecorder).Events) > 0 {
+				if e := <-r.recorder.(*record.FakeRecorder).Events; c.event != "" && strings.Contains(e, c.event) {
+					found = true
+				}
+			}
+			if found != (c.event != "") {
+				t.Fatalf("expected event %q to be emitted", c.event)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package approval
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/notifier"
+)
+
+// escalate applies the escalation steps due, records them to status.escalations and emits an event for each step
+func (r *ReconcileApproval) escalate(cr *tmaxv1.Approval) error {
+	due := cr.DueEscalationLevel(time.Now())
+	if due <= cr.Status.EscalationLevel {
+		return nil
+	}
+
+	now := metav1.NewTime(time.Now())
+	var messages []string
+	for level := cr.Status.EscalationLevel; level < due; level++ {
+		msg := escalationMessage(level+1, cr.Spec.Escalations[level])
+		messages = append(messages, msg)
+		cr.Status.Escalations = append(cr.Status.Escalations, tmaxv1.EscalationStatus{
+			Level:   level + 1,
+			Stage:   cr.Status.CurrentStage,
+			Time:    now,
+			Message: msg,
+		})
+	}
+	cr.Status.EscalationLevel = due
+
+	if err := r.client.Status().Update(context.TODO(), cr); err != nil {
+		return err
+	}
+
+	for i, msg := range messages {
+		r.recorder.Event(cr, corev1.EventTypeWarning, "Escalated", msg)
+		r.notify(cr, notifier.EventEscalated, cr.Spec.Escalations[int(due)-len(messages)+i].Users, msg)
+	}
+	return nil
+}
+
+func escalationMessage(level int32, step tmaxv1.EscalationStep) string {
+	var users []string
+	for u := range step.Users {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+	return fmt.Sprintf("escalated to level %d after %s, users: [%s], groups: [%s]", level, step.After.Duration,
+		strings.Join(users, ", "), strings.Join(step.Groups, ", "))
+}
@@ -0,0 +1,92 @@
+package approval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func TestReconcile_Escalation(t *testing.T) {
+	server := newTaskServer(t)
+	defer server.Close()
+
+	// Waiting for a minute, so the first step is due but the second is not
+	cr := newTestApproval(t, server)
+	cr.Spec.Threshold = 2
+	cr.Spec.Quorum = &tmaxv1.Quorum{Percentage: 50}
+	cr.Spec.Escalations = []tmaxv1.EscalationStep{
+		{After: metav1.Duration{Duration: 30 * time.Second}, Users: map[string]string{"carol": "carol@tmax.co.kr"}},
+		{After: metav1.Duration{Duration: time.Hour}, Users: map[string]string{"dave": "dave@tmax.co.kr"}},
+	}
+	cr.Status.Conditions[0].Reason = "Initialized"
+	r := newTestReconciler(t, cr)
+
+	_, instance := reconcileApproval(t, r, cr)
+
+	if instance.Status.EscalationLevel != 1 {
+		t.Fatalf("expected escalation level 1, got %d", instance.Status.EscalationLevel)
+	}
+	if len(instance.Status.Escalations) != 1 {
+		t.Fatalf("expected an escalation to be recorded, got %+v", instance.Status.Escalations)
+	}
+	if e := instance.Status.Escalations[0]; e.Level != 1 || e.Stage != 0 || e.Time.IsZero() || e.Message == "" {
+		t.Fatalf("expected level and time of the escalation to be recorded, got %+v", e)
+	}
+	if cond := instance.Status.GetCondition(tmaxv1.ConditionWaiting); cond == nil || cond.Reason != "Initialized" {
+		t.Fatalf("expected Waiting condition to be kept, got %+v", instance.Status.Conditions)
+	}
+	if events := r.recorder.(*record.FakeRecorder).Events; len(events) != 1 {
+		t.Fatalf("expected an event to be emitted, got %d", len(events))
+	}
+	if _, listed := instance.ActivePolicy().Users["carol"]; !listed {
+		t.Fatalf("expected carol to be an approver, got %+v", instance.ActivePolicy().Users)
+	}
+
+	// Reconciled again, nothing is escalated
+	_, instance = reconcileApproval(t, r, instance)
+	if instance.Status.EscalationLevel != 1 || len(instance.Status.Escalations) != 1 {
+		t.Fatalf("expected no more escalation, got %+v", instance.Status.Escalations)
+	}
+
+	// Escalated user is counted toward the threshold, but not toward the quorum percentage
+	instance.Status.Approvers = []tmaxv1.Approver{vote("carol", tmaxv1.DecisionApproved), vote("alice", tmaxv1.DecisionApproved)}
+	if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	_, instance = reconcileApproval(t, r, instance)
+
+	if len(server.received) != 1 || server.received[0].Decision != tmaxv1.DecisionApproved {
+		t.Fatalf("expected Approved to be sent, got %+v", server.received)
+	}
+	if len(instance.Status.Escalations) != 1 {
+		t.Fatalf("expected the escalation record to be kept, got %+v", instance.Status.Escalations)
+	}
+}
+
+func TestReconcile_EscalatedNotInQuorum(t *testing.T) {
+	server := newTaskServer(t)
+	defer server.Close()
+
+	cr := newTestApproval(t, server)
+	cr.Spec.Quorum = &tmaxv1.Quorum{Percentage: 50}
+	cr.Spec.Escalations = []tmaxv1.EscalationStep{
+		{After: metav1.Duration{Duration: 30 * time.Second}, Users: map[string]string{"carol": "carol@tmax.co.kr", "dave": "dave@tmax.co.kr"}},
+	}
+	cr.Status.EscalationLevel = 1
+	cr.Status.Approvers = []tmaxv1.Approver{vote("carol", tmaxv1.DecisionApproved), vote("dave", tmaxv1.DecisionApproved)}
+
+	_, instance := reconcileApproval(t, newTestReconciler(t, cr), cr)
+
+	// Half of the four users approved, but none of the two requested
+	if len(server.received) != 0 {
+		t.Fatalf("expected no decision to be sent, got %+v", server.received)
+	}
+	if instance.Status.IsFinal() {
+		t.Fatalf("expected to be waiting, got %+v", instance.Status.Conditions)
+	}
+}
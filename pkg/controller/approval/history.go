@@ -0,0 +1,71 @@
+package approval
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// recordHistory appends the votes cast or withdrawn since the last record to the history.
+// The votes of the stage in progress are derived by replaying the history, and compared with status.approvers.
+// Events are stamped with the time observed, as the approved time is given by the approver.
+// It returns true if any event is recorded
+func recordHistory(cr *tmaxv1.Approval, now time.Time) bool {
+	stage := cr.Status.CurrentStage
+
+	// Replay the history of the stage in progress
+	last := map[string]tmaxv1.VoteEvent{}
+	var order []string
+	for _, e := range cr.Status.History {
+		if e.Stage != stage {
+			continue
+		}
+		u := e.UserID
+		if e.OnBehalfOf != "" {
+			u = e.OnBehalfOf
+		}
+		if _, exist := last[u]; !exist {
+			order = append(order, u)
+		}
+		last[u] = e
+	}
+
+	recorded := false
+
+	// Cast, or changed
+	for _, a := range cr.Status.Approvers {
+		e, exist := last[a.EffectiveUser()]
+		if exist && e.Action == tmaxv1.VoteCast && e.Decision == a.Decision && e.UserID == a.UserID && e.ApprovedTime.Equal(&a.ApprovedTime) {
+			continue
+		}
+		cr.Status.History = append(cr.Status.History, tmaxv1.VoteEvent{
+			UserID:       a.UserID,
+			Action:       tmaxv1.VoteCast,
+			Decision:     a.Decision,
+			Time:         metav1.NewTime(now),
+			ApprovedTime: a.ApprovedTime.DeepCopy(),
+			OnBehalfOf:   a.OnBehalfOf,
+			Stage:        stage,
+		})
+		recorded = true
+	}
+
+	// Withdrawn
+	for _, u := range order {
+		if last[u].Action != tmaxv1.VoteCast || cr.Status.GetEffectiveApprover(u) != nil {
+			continue
+		}
+		cr.Status.History = append(cr.Status.History, tmaxv1.VoteEvent{
+			UserID:     last[u].UserID,
+			Action:     tmaxv1.VoteWithdraw,
+			Time:       metav1.NewTime(now),
+			OnBehalfOf: last[u].OnBehalfOf,
+			Stage:      stage,
+		})
+		recorded = true
+	}
+
+	return recorded
+}
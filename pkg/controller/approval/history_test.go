@@ -0,0 +1,66 @@
+package approval
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func TestRecordHistory(t *testing.T) {
+	t1 := metav1.NewTime(time.Unix(1000, 0))
+	t2 := metav1.NewTime(time.Unix(2000, 0))
+	now := time.Unix(3000, 0)
+
+	cr := &tmaxv1.Approval{}
+
+	// Cast
+	cr.Status.Approvers = []tmaxv1.Approver{{UserID: "alice", Decision: tmaxv1.DecisionApproved, ApprovedTime: t1}}
+	if !recordHistory(cr, now) || len(cr.Status.History) != 1 || cr.Status.History[0].Action != tmaxv1.VoteCast {
+		t.Fatalf("expected a cast event, got %+v", cr.Status.History)
+	}
+	// Stamped with the time observed, not with the time claimed by the approver
+	if e := cr.Status.History[0]; !e.Time.Time.Equal(now) || e.ApprovedTime == nil || !e.ApprovedTime.Equal(&t1) {
+		t.Fatalf("expected the event to be observed at %s and approved at %s, got %+v", now, t1, e)
+	}
+
+	// Nothing changed
+	if recordHistory(cr, now) {
+		t.Fatalf("expected no event, got %+v", cr.Status.History)
+	}
+
+	// Cast again with the same decision
+	cr.Status.Approvers[0].ApprovedTime = t2
+	if !recordHistory(cr, now) || len(cr.Status.History) != 2 {
+		t.Fatalf("expected a cast event of the same decision, got %+v", cr.Status.History)
+	}
+	cr.Status.History = cr.Status.History[:1]
+	cr.Status.Approvers[0].ApprovedTime = t1
+
+	// Changed
+	cr.Status.Approvers[0].Decision = tmaxv1.DecisionRejected
+	cr.Status.Approvers[0].ApprovedTime = t2
+	if !recordHistory(cr, now) || len(cr.Status.History) != 2 || cr.Status.History[1].Decision != tmaxv1.DecisionRejected {
+		t.Fatalf("expected a cast event of rejection, got %+v", cr.Status.History)
+	}
+
+	// Withdrawn
+	cr.Status.Approvers = nil
+	if !recordHistory(cr, now) || len(cr.Status.History) != 3 || cr.Status.History[2].Action != tmaxv1.VoteWithdraw {
+		t.Fatalf("expected a withdraw event, got %+v", cr.Status.History)
+	}
+	if recordHistory(cr, now) {
+		t.Fatalf("expected no event after withdrawal, got %+v", cr.Status.History)
+	}
+
+	// Approvers are cleared when proceeding to the next stage, which is not a withdrawal
+	cr.Status.Approvers = []tmaxv1.Approver{{UserID: "bob", Decision: tmaxv1.DecisionApproved, ApprovedTime: t2}}
+	recordHistory(cr, now)
+	cr.Status.CurrentStage = 1
+	cr.Status.Approvers = nil
+	if recordHistory(cr, now) {
+		t.Fatalf("expected no event at the next stage, got %+v", cr.Status.History)
+	}
+}
@@ -0,0 +1,159 @@
+package approval
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"approval-operator/internal"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/notifier"
+	"approval-operator/pkg/token"
+)
+
+// notify notifies the recipients of the event, if notification is configured in the namespace of the approval.
+// Decision links are sent separately, only by the mail configured in the operator namespace.
+// Failure of the notification is recorded as an event, and doesn't affect the approving process
+func (r *ReconcileApproval) notify(cr *tmaxv1.Approval, event notifier.EventType, recipients map[string]string, message string) {
+	reqLogger := log.WithValues("Request.Namespace", cr.Namespace, "Request.Name", cr.Name)
+	if len(recipients) == 0 {
+		return
+	}
+
+	config, err := notifier.Load(context.TODO(), r.client, cr.Namespace)
+	if err != nil {
+		reqLogger.Error(err, "Failed to load notifier configuration")
+		return
+	}
+	if config != nil {
+		r.notifyWith(cr, config, &notifier.Notification{
+			Event:      event,
+			Approval:   cr,
+			Recipients: recipients,
+			Message:    message,
+			Pod:        r.requestingPod(cr),
+			Link:       config.Link(cr),
+			Templates:  config.Templates,
+		})
+	}
+
+	if event != notifier.EventDecided {
+		r.notifyDecisionLinks(cr, event, recipients, message)
+	}
+}
+
+// notifyWith sends the notification with the configuration, reporting the errors of the configuration
+func (r *ReconcileApproval) notifyWith(cr *tmaxv1.Approval, config *notifier.Config, notification *notifier.Notification) {
+	reqLogger := log.WithValues("Request.Namespace", cr.Namespace, "Request.Name", cr.Name)
+
+	// Notifiers to the hosts not allowed are skipped
+	if config.HostError != nil {
+		reqLogger.Info(config.HostError.Error())
+		r.recorder.Event(cr, corev1.EventTypeWarning, "NotifierNotAllowed", config.HostError.Error())
+	}
+
+	// Broken templates are reported, and the notification is sent with the default templates
+	if config.TemplateError != nil {
+		reqLogger.Info(config.TemplateError.Error())
+		r.recorder.Event(cr, corev1.EventTypeWarning, "InvalidTemplate", config.TemplateError.Error())
+	}
+
+	// Templates may fail with the actual data, e.g., the pod is already deleted
+	if _, _, _, err := notification.Render(); err != nil {
+		msg := fmt.Sprintf("failed to render template in ConfigMap %s, default template is used: %s", notifier.ConfigName, err.Error())
+		reqLogger.Info(msg)
+		r.recorder.Event(cr, corev1.EventTypeWarning, "InvalidTemplate", msg)
+		notification.Templates = nil
+	}
+
+	if err := config.Notifier.Notify(context.TODO(), notification); err != nil {
+		reqLogger.Error(err, "Failed to notify approvers")
+		r.recorder.Event(cr, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+	}
+}
+
+// notifyDecisionLinks mails each recipient one's own decision links, if the mail and the link base are configured in
+// the operator namespace. The links are signed tokens deciding as the recipient, so they are never sent by the
+// notifiers or rendered by the templates of the namespace of the approval, which the requester may control
+func (r *ReconcileApproval) notifyDecisionLinks(cr *tmaxv1.Approval, event notifier.EventType, recipients map[string]string, message string) {
+	reqLogger := log.WithValues("Request.Namespace", cr.Namespace, "Request.Name", cr.Name)
+
+	ns, err := internal.Namespace()
+	if err != nil {
+		reqLogger.Error(err, "Failed to get operator namespace")
+		return
+	}
+	config, err := notifier.Load(context.TODO(), r.client, ns)
+	if err != nil {
+		reqLogger.Error(err, "Failed to load notifier configuration of operator namespace")
+		return
+	}
+	if config == nil || config.LinkBase == "" {
+		return
+	}
+	mail := config.Mail()
+	if mail == nil {
+		return
+	}
+
+	key, err := token.EnsureKey(context.TODO(), r.client)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get token key")
+		return
+	}
+	for user, addr := range recipients {
+		if addr == "" {
+			continue
+		}
+		links, err := decisionLinks(config, key, cr, user)
+		if err != nil {
+			reqLogger.Error(err, "Failed to sign decision token")
+			return
+		}
+		n := &notifier.Notification{
+			Event:         event,
+			Approval:      cr,
+			Recipients:    map[string]string{user: addr},
+			Message:       message,
+			Link:          config.Link(cr),
+			DecisionLinks: links,
+		}
+		if err := mail.Notify(context.TODO(), n); err != nil {
+			reqLogger.Error(err, "Failed to mail decision links")
+			r.recorder.Event(cr, corev1.EventTypeWarning, "NotificationFailed", err.Error())
+		}
+	}
+}
+
+// decisionLinks returns the one-click links for the user to approve or reject the approval
+func decisionLinks(config *notifier.Config, key []byte, cr *tmaxv1.Approval, user string) (map[tmaxv1.DecisionType]string, error) {
+	links := map[tmaxv1.DecisionType]string{}
+	for _, d := range []tmaxv1.DecisionType{tmaxv1.DecisionApproved, tmaxv1.DecisionRejected} {
+		claims, err := token.NewClaims(cr, user, d, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		t, err := token.Sign(key, claims)
+		if err != nil {
+			return nil, err
+		}
+		links[d] = config.DecisionLink(t)
+	}
+	return links, nil
+}
+
+// requestingPod returns the pod requested the approval, or nil if it is not found
+func (r *ReconcileApproval) requestingPod(cr *tmaxv1.Approval) *corev1.Pod {
+	ref := RequestingPod(cr)
+	if ref == nil {
+		return nil
+	}
+	pod := &corev1.Pod{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: cr.Namespace}, pod); err != nil {
+		return nil
+	}
+	return pod
+}
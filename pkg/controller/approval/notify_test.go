@@ -0,0 +1,95 @@
+package approval
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/notifier"
+)
+
+func TestNotify_NamespaceConfigGetsNoDecisionLinks(t *testing.T) {
+	var payloads []notifier.WebhookPayload
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := notifier.WebhookPayload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, p)
+	}))
+	defer hook.Close()
+	u, err := url.Parse(hook.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := map[string]struct {
+		allowedHosts string
+		notified     bool
+	}{
+		"allowed":    {allowedHosts: u.Hostname(), notified: true},
+		"notAllowed": {allowedHosts: "chat.tmax.co.kr", notified: false},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			payloads = nil
+
+			// Operator namespace is default, when not running in a cluster
+			operatorConfig := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: notifier.ConfigName, Namespace: "default"},
+				Data: map[string]string{
+					notifier.AllowedHostsKey: c.allowedHosts,
+					notifier.LinkBaseKey:     "https://approval.tmax.co.kr",
+				},
+			}
+			// Requester's namespace asks for the links to be posted to its own webhook
+			namespaceConfig := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: notifier.ConfigName, Namespace: "team"},
+				Data: map[string]string{
+					notifier.WebhookURLKey:      hook.URL,
+					notifier.LinkBaseKey:        "https://evil.example.com",
+					notifier.BodyTemplateKey:    "{{ .Link }}",
+					notifier.SubjectTemplateKey: "{{ .Event }}",
+				},
+			}
+			cr := &tmaxv1.Approval{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "team", UID: "approval-uid"},
+				Spec: tmaxv1.ApprovalSpec{
+					ApproverPolicy: tmaxv1.ApproverPolicy{Threshold: 1, Users: map[string]string{"alice": "alice@tmax.co.kr"}},
+				},
+			}
+			r := newTestReconciler(t, operatorConfig, namespaceConfig, cr)
+
+			r.notify(cr, notifier.EventCreated, cr.Spec.Users, "")
+
+			if !c.notified {
+				if len(payloads) != 0 {
+					t.Fatalf("expected the webhook not allowed not to be called, got %+v", payloads)
+				}
+				if events := r.recorder.(*record.FakeRecorder).Events; len(events) == 0 || !strings.Contains(<-events, "NotifierNotAllowed") {
+					t.Fatal("expected the webhook not allowed to be reported")
+				}
+				return
+			}
+			if len(payloads) != 1 {
+				t.Fatalf("expected a notification, got %d", len(payloads))
+			}
+			raw, err := json.Marshal(payloads[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(raw), "token=") || strings.Contains(string(raw), "/decide") {
+				t.Fatalf("expected no decision link to be posted, got %s", raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package approval
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// RequestingPod returns the owner reference of the pod requested the approval, or nil if there is not
+func RequestingPod(approval *tmaxv1.Approval) *metav1.OwnerReference {
+	for i, ref := range approval.OwnerReferences {
+		if ref.APIVersion == "v1" && ref.Kind == "Pod" {
+			return &approval.OwnerReferences[i]
+		}
+	}
+	return nil
+}
+
+// podTermination returns the reason why the requesting pod is regarded as terminated,
+// or an empty string if the pod is alive or the approval is not requested by a pod
+func (r *ReconcileApproval) podTermination(cr *tmaxv1.Approval) (string, error) {
+	ref := RequestingPod(cr)
+	if ref == nil {
+		return "", nil
+	}
+
+	pod := &corev1.Pod{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: cr.Namespace}, pod); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("pod %s is deleted", ref.Name), nil
+		}
+		return "", err
+	}
+
+	switch {
+	case pod.UID != ref.UID:
+		return fmt.Sprintf("pod %s is replaced by another pod", ref.Name), nil
+	case pod.DeletionTimestamp != nil:
+		return fmt.Sprintf("pod %s is being deleted", ref.Name), nil
+	case pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed:
+		return fmt.Sprintf("pod %s is in %s phase", ref.Name, pod.Status.Phase), nil
+	case pod.Status.PodIP != "" && pod.Status.PodIP != cr.Spec.PodIP:
+		return fmt.Sprintf("IP of pod %s is changed to %s", ref.Name, pod.Status.PodIP), nil
+	}
+
+	return "", nil
+}
+
+// ownedApprovalsMapper maps a pod to the approvals requested by the pod
+type ownedApprovalsMapper struct {
+	client client.Client
+}
+
+func (m *ownedApprovalsMapper) Map(obj handler.MapObject) []reconcile.Request {
+	approvals := &tmaxv1.ApprovalList{}
+	if err := m.client.List(context.TODO(), approvals, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		log.Error(err, "Failed to list approvals for pod", "Pod.Namespace", obj.Meta.GetNamespace(), "Pod.Name", obj.Meta.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range approvals.Items {
+		if ref := RequestingPod(&approvals.Items[i]); ref != nil && ref.UID == obj.Meta.GetUID() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      approvals.Items[i].Name,
+				Namespace: approvals.Items[i].Namespace,
+			}})
+		}
+	}
+	return requests
+}
+
+// podTerminationPredicate passes only the pod events which can terminate the approval
+func podTerminationPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPod, ok := e.ObjectOld.(*corev1.Pod)
+			if !ok {
+				return false
+			}
+			newPod, ok := e.ObjectNew.(*corev1.Pod)
+			if !ok {
+				return false
+			}
+			return oldPod.Status.Phase != newPod.Status.Phase ||
+				oldPod.Status.PodIP != newPod.Status.PodIP ||
+				(oldPod.DeletionTimestamp == nil) != (newPod.DeletionTimestamp == nil)
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return true
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
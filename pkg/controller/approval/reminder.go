@@ -0,0 +1,32 @@
+package approval
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/notifier"
+)
+
+// remind notifies the users who have not decided yet, if the reminder interval has passed.
+// The reminder time is recorded before notifying, so that the users are not reminded again on restart of the operator
+func (r *ReconcileApproval) remind(cr *tmaxv1.Approval) error {
+	next := cr.NextReminderTime()
+	now := time.Now()
+	if next == nil || now.Before(*next) {
+		return nil
+	}
+
+	reminded := metav1.NewTime(now)
+	cr.Status.LastReminderTime = &reminded
+	if err := r.client.Status().Update(context.TODO(), cr); err != nil {
+		return err
+	}
+
+	if pending := cr.PendingUsers(); len(pending) > 0 {
+		r.notify(cr, notifier.EventReminder, pending, "your decision is pending")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/notifier"
+)
+
+func TestReconcile_Reminder(t *testing.T) {
+	var payloads []notifier.WebhookPayload
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := notifier.WebhookPayload{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, p)
+	}))
+	defer hook.Close()
+
+	server := newTaskServer(t)
+	defer server.Close()
+
+	// Waiting for a minute, so the first reminder is due. Alice decided, but the threshold is not met yet
+	cr := newTestApproval(t, server)
+	cr.Spec.Threshold = 2
+	cr.Spec.ReminderInterval = &metav1.Duration{Duration: 30 * time.Second}
+	cr.Status.Approvers = []tmaxv1.Approver{vote("alice", tmaxv1.DecisionApproved)}
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: notifier.ConfigName, Namespace: cr.Namespace},
+		Data:       map[string]string{notifier.WebhookURLKey: hook.URL},
+	}
+	r := newTestReconciler(t, cr, config)
+
+	result, instance := reconcileApproval(t, r, cr)
+
+	if instance.Status.LastReminderTime == nil || time.Since(instance.Status.LastReminderTime.Time) > time.Minute {
+		t.Fatalf("expected the reminder time to be recorded, got %v", instance.Status.LastReminderTime)
+	}
+	if len(payloads) != 1 || payloads[0].Event != notifier.EventReminder {
+		t.Fatalf("expected a reminder, got %+v", payloads)
+	}
+	if _, notified := payloads[0].Recipients["bob"]; len(payloads[0].Recipients) != 1 || !notified {
+		t.Fatalf("expected only bob to be reminded, got %+v", payloads[0].Recipients)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > 30*time.Second {
+		t.Fatalf("expected to be requeued at the next reminder, got %s", result.RequeueAfter)
+	}
+
+	// Reconciled again before the interval passes, nobody is reminded
+	_, instance = reconcileApproval(t, r, instance)
+	if len(payloads) != 1 {
+		t.Fatalf("expected no more reminder, got %+v", payloads)
+	}
+
+	// Once the approval ended, nobody is reminded even if the interval has passed
+	past := metav1.NewTime(time.Now().Add(-time.Hour))
+	instance.Status.LastReminderTime = &past
+	instance.Status.Conditions = tmaxv1.Conditions{{Type: tmaxv1.ConditionApproved, Status: corev1.ConditionTrue}}
+	if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	reconcileApproval(t, r, instance)
+	if len(payloads) != 1 {
+		t.Fatalf("expected no reminder after the approval ended, got %+v", payloads)
+	}
+}
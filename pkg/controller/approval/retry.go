@@ -0,0 +1,51 @@
+package approval
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultMaxRetry     = 5
+	DefaultRetryBackoff = 5 * time.Second
+	MaxRetryBackoff     = 10 * time.Minute
+
+	// CallbackTimeout bounds each attempt to send a message to the task
+	CallbackTimeout = 10 * time.Second
+)
+
+// MaxRetry returns the number of attempts to send a decision to the task, before the approval is marked as Failed
+func MaxRetry() int32 {
+	envRetry := os.Getenv("CALLBACK_MAX_RETRY")
+	if envRetry == "" {
+		return DefaultMaxRetry
+	}
+	retry, err := strconv.Atoi(envRetry)
+	if err == nil && retry < 1 {
+		err = fmt.Errorf("max retry %d is not positive", retry)
+	}
+	if err != nil {
+		log.Error(err, "Cannot parse max retry, it should be a positive integer")
+		os.Exit(1)
+	}
+	return int32(retry)
+}
+
+// RetryBackoff returns the initial backoff between the attempts to send a decision to the task
+func RetryBackoff() time.Duration {
+	envBackoff := os.Getenv("CALLBACK_RETRY_BACKOFF")
+	if envBackoff == "" {
+		return DefaultRetryBackoff
+	}
+	backoff, err := time.ParseDuration(envBackoff)
+	if err == nil && backoff <= 0 {
+		err = fmt.Errorf("retry backoff %s is not positive", backoff)
+	}
+	if err != nil {
+		log.Error(err, "Cannot parse retry backoff, it should be a positive duration")
+		os.Exit(1)
+	}
+	return backoff
+}
@@ -0,0 +1,47 @@
+package approval
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func newStageStatus(stage tmaxv1.ApprovalStage) tmaxv1.StageStatus {
+	return tmaxv1.StageStatus{
+		Name:      stage.Name,
+		Result:    tmaxv1.ConditionWaiting,
+		StartTime: metav1.NewTime(time.Now()),
+	}
+}
+
+// completeStage records the result and the decisions of the stage in progress.
+// It does nothing for a single stage approval
+func completeStage(cr *tmaxv1.Approval, result tmaxv1.ConditionType) {
+	i := int(cr.Status.CurrentStage)
+	if len(cr.Spec.Stages) == 0 || i >= len(cr.Status.Stages) {
+		return
+	}
+
+	now := metav1.NewTime(time.Now())
+	cr.Status.Stages[i].Result = result
+	cr.Status.Stages[i].CompletionTime = &now
+	cr.Status.Stages[i].Approvers = cr.Status.Approvers
+}
+
+// nextStage completes the stage in progress as approved and starts the next stage.
+// Decisions of the completed stage are moved to its stage status, so that status.approvers only holds the
+// decisions of the next stage
+func (r *ReconcileApproval) nextStage(cr *tmaxv1.Approval) error {
+	completeStage(cr, tmaxv1.ConditionApproved)
+
+	cr.Status.CurrentStage++
+	cr.Status.Approvers = nil
+	cr.Status.EscalationLevel = 0
+	cr.Status.LastReminderTime = nil
+	cr.Status.Stages = append(cr.Status.Stages, newStageStatus(cr.Spec.Stages[cr.Status.CurrentStage]))
+
+	return r.client.Status().Update(context.TODO(), cr)
+}
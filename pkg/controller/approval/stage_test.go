@@ -0,0 +1,152 @@
+package approval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// newStagedApproval returns an approval of two stages, approved by alice and then by bob
+func newStagedApproval(t *testing.T, server *taskServer) *tmaxv1.Approval {
+	cr := newTestApproval(t, server)
+	cr.Spec.ApproverPolicy = tmaxv1.ApproverPolicy{}
+	cr.Spec.Stages = []tmaxv1.ApprovalStage{
+		{Name: "team", ApproverPolicy: tmaxv1.ApproverPolicy{Threshold: 1, Users: map[string]string{"alice": "alice@tmax.co.kr"}}},
+		{Name: "security", ApproverPolicy: tmaxv1.ApproverPolicy{Threshold: 1, Users: map[string]string{"bob": "bob@tmax.co.kr"}}},
+	}
+	cr.Status.Stages = []tmaxv1.StageStatus{{Name: "team", Result: tmaxv1.ConditionWaiting, StartTime: metav1.Now()}}
+	return cr
+}
+
+func vote(user string, decision tmaxv1.DecisionType) tmaxv1.Approver {
+	return tmaxv1.Approver{UserID: user, Decision: decision, ApprovedTime: metav1.NewTime(time.Now())}
+}
+
+func TestReconcile_NextStage(t *testing.T) {
+	server := newTaskServer(t)
+	defer server.Close()
+
+	cr := newStagedApproval(t, server)
+	cr.Status.Approvers = []tmaxv1.Approver{vote("alice", tmaxv1.DecisionApproved)}
+	r := newTestReconciler(t, cr)
+
+	_, instance := reconcileApproval(t, r, cr)
+
+	// Approval of a stage other than the last one is not sent to the task
+	if len(server.received) != 0 {
+		t.Fatalf("expected no decision to be sent, got %+v", server.received)
+	}
+	if instance.Status.IsFinal() {
+		t.Fatalf("expected to be waiting, got %+v", instance.Status.Conditions)
+	}
+	if instance.Status.CurrentStage != 1 || len(instance.Status.Stages) != 2 {
+		t.Fatalf("expected the second stage to be started, got stage %d of %+v", instance.Status.CurrentStage, instance.Status.Stages)
+	}
+	first, second := instance.Status.Stages[0], instance.Status.Stages[1]
+	if first.Result != tmaxv1.ConditionApproved || first.CompletionTime == nil || len(first.Approvers) != 1 || first.Approvers[0].UserID != "alice" {
+		t.Fatalf("expected the first stage to be approved by alice, got %+v", first)
+	}
+	if second.Name != "security" || second.Result != tmaxv1.ConditionWaiting {
+		t.Fatalf("expected the second stage to be waiting, got %+v", second)
+	}
+	if len(instance.Status.Approvers) != 0 {
+		t.Fatalf("expected the decisions of the first stage to be moved, got %+v", instance.Status.Approvers)
+	}
+	if _, listed := instance.ActivePolicy().Users["bob"]; !listed {
+		t.Fatalf("expected the policy of the second stage to be active, got %+v", instance.ActivePolicy())
+	}
+
+	// Approval of the last stage is sent to the task
+	instance.Status.Approvers = []tmaxv1.Approver{vote("bob", tmaxv1.DecisionApproved)}
+	if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	_, instance = reconcileApproval(t, r, instance)
+
+	if len(server.received) != 1 || server.received[0].Decision != tmaxv1.DecisionApproved {
+		t.Fatalf("expected Approved to be sent, got %+v", server.received)
+	}
+	if cond := instance.Status.GetCondition(tmaxv1.ConditionApproved); cond == nil || !cond.IsTrue() {
+		t.Fatalf("expected Approved condition, got %+v", instance.Status.Conditions)
+	}
+	if last := instance.Status.Stages[1]; last.Result != tmaxv1.ConditionApproved || len(last.Approvers) != 1 {
+		t.Fatalf("expected the last stage to be approved by bob, got %+v", last)
+	}
+}
+
+func TestReconcile_StageRejected(t *testing.T) {
+	tc := map[string]struct {
+		stage     int32
+		approvers []tmaxv1.Approver
+	}{
+		"firstStage":  {stage: 0, approvers: []tmaxv1.Approver{vote("alice", tmaxv1.DecisionRejected)}},
+		"secondStage": {stage: 1, approvers: []tmaxv1.Approver{vote("bob", tmaxv1.DecisionRejected)}},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			server := newTaskServer(t)
+			defer server.Close()
+
+			cr := newStagedApproval(t, server)
+			if c.stage == 1 {
+				now := metav1.Now()
+				cr.Status.Stages[0].Result = tmaxv1.ConditionApproved
+				cr.Status.Stages[0].CompletionTime = &now
+				cr.Status.Stages = append(cr.Status.Stages, tmaxv1.StageStatus{Name: "security", Result: tmaxv1.ConditionWaiting, StartTime: now})
+			}
+			cr.Status.CurrentStage = c.stage
+			cr.Status.Approvers = c.approvers
+
+			_, instance := reconcileApproval(t, newTestReconciler(t, cr), cr)
+
+			// Rejection in any stage rejects the whole approval
+			if len(server.received) != 1 || server.received[0].Decision != tmaxv1.DecisionRejected {
+				t.Fatalf("expected Rejected to be sent, got %+v", server.received)
+			}
+			if cond := instance.Status.GetCondition(tmaxv1.ConditionRejected); cond == nil || !cond.IsTrue() {
+				t.Fatalf("expected Rejected condition, got %+v", instance.Status.Conditions)
+			}
+			if instance.Status.CurrentStage != c.stage || len(instance.Status.Stages) != int(c.stage)+1 {
+				t.Fatalf("expected no further stage to be started, got stage %d of %+v", instance.Status.CurrentStage, instance.Status.Stages)
+			}
+			if result := instance.Status.Stages[c.stage].Result; result != tmaxv1.ConditionRejected {
+				t.Fatalf("expected the stage to be rejected, got %s", result)
+			}
+		})
+	}
+}
+
+func TestReconcile_Comments(t *testing.T) {
+	server := newTaskServer(t)
+	defer server.Close()
+
+	// Comments of the completed stage and of the stage in progress are forwarded together
+	cr := newStagedApproval(t, server)
+	cr.Status.CurrentStage = 1
+	team := vote("alice", tmaxv1.DecisionApproved)
+	team.Comment = "looks good"
+	cr.Status.Stages = []tmaxv1.StageStatus{
+		{Name: "team", Result: tmaxv1.ConditionApproved, Approvers: []tmaxv1.Approver{team}},
+		{Name: "security", Result: tmaxv1.ConditionWaiting, StartTime: metav1.Now()},
+	}
+	security := vote("bob", tmaxv1.DecisionApproved)
+	security.Comment = "scanned"
+	silent := vote("carol", tmaxv1.DecisionAbstain)
+	cr.Status.Approvers = []tmaxv1.Approver{security, silent}
+	r := newTestReconciler(t, cr)
+
+	reconcileApproval(t, r, cr)
+
+	if len(server.received) != 1 || server.received[0].Decision != tmaxv1.DecisionApproved {
+		t.Fatalf("expected Approved to be sent, got %+v", server.received)
+	}
+	got := server.received[0].Comments
+	if len(got) != 2 || got[0].UserID != "alice" || got[0].Comment != team.Comment || got[1].UserID != "bob" || got[1].Comment != security.Comment {
+		t.Fatalf("expected the comments of alice and bob to be forwarded, got %+v", got)
+	}
+}
@@ -0,0 +1,204 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"approval-operator/internal"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// Notifiers are configured per namespace, with the ConfigMap and the optional Secret of the name
+const (
+	ConfigName = "approval-notifier"
+
+	// ConfigMap keys
+	SMTPAddrKey   = "smtp.addr"
+	SMTPFromKey   = "smtp.from"
+	WebhookURLKey = "webhook.url"
+	// LinkBaseKey is the base url of the links to the approvals, e.g., https://approval.tmax.co.kr.
+	// Decision links are issued only with the configuration of the operator namespace
+	LinkBaseKey = "link.base"
+	// AllowedHostsKey is the comma separated hosts the SMTP relays and the webhooks configured in the namespaces of
+	// the approvals can connect to. It is read only from the configuration of the operator namespace
+	AllowedHostsKey = "allowed.hosts"
+
+	// Secret keys
+	SMTPUsernameKey = "smtp.username"
+	SMTPPasswordKey = "smtp.password"
+	WebhookTokenKey = "webhook.token"
+
+	WebhookTimeout = 10 * time.Second
+	SMTPTimeout    = 10 * time.Second
+)
+
+// Config is the notification configuration of a namespace
+type Config struct {
+	Notifier  Notifier
+	Templates *Templates
+	LinkBase  string
+
+	// TemplateError is the error of the templates. The default templates are used if it is not nil
+	TemplateError error
+	// HostError is the error of the notifiers not used, as their hosts are not allowed by the operator
+	HostError error
+
+	// operator is true if the configuration is of the operator namespace, which only the operator admins can change
+	operator bool
+}
+
+// Mail returns the SMTP notifiers of the configuration of the operator namespace, which can carry the decision links.
+// It returns nil for the configuration of other namespaces, as anyone who can edit it could collect the links
+func (c *Config) Mail() Notifier {
+	if !c.operator {
+		return nil
+	}
+	var mail Multi
+	if multi, ok := c.Notifier.(Multi); ok {
+		for _, n := range multi {
+			if s, ok := n.(*SMTP); ok {
+				mail = append(mail, s)
+			}
+		}
+	}
+	if len(mail) == 0 {
+		return nil
+	}
+	return mail
+}
+
+// Link returns the link to the approval, or an empty string if the base url is not configured
+func (c *Config) Link(approval *tmaxv1.Approval) string {
+	if c.LinkBase == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/approval/%s/%s", strings.TrimSuffix(c.LinkBase, "/"), approval.Namespace, approval.Name)
+}
+
+// DecisionLink returns the one-click link redeeming the token, or an empty string if the base url is not configured
+func (c *Config) DecisionLink(token string) string {
+	if c.LinkBase == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/decide?token=%s", strings.TrimSuffix(c.LinkBase, "/"), url.QueryEscape(token))
+}
+
+// Load returns the notification configuration of the namespace, or nil if notification is not configured.
+// The SMTP relays and the webhooks of a namespace other than the operator namespace are used only if their hosts
+// are allowed in the configuration of the operator namespace, so that the operator does not connect to any host
+// the namespace chooses
+func Load(ctx context.Context, c client.Client, namespace string) (*Config, error) {
+	operatorNamespace, err := internal.Namespace()
+	if err != nil {
+		return nil, err
+	}
+	config, err := load(ctx, c, namespace)
+	if err != nil || config == nil {
+		return config, err
+	}
+	if namespace == operatorNamespace {
+		config.operator = true
+		return config, nil
+	}
+
+	allowed := map[string]bool{}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ConfigName, Namespace: operatorNamespace}, cm); err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	for _, h := range strings.Split(cm.Data[AllowedHostsKey], ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			allowed[h] = true
+		}
+	}
+
+	var notifiers Multi
+	var disallowed []string
+	for _, n := range config.Notifier.(Multi) {
+		if host := notifierHost(n); allowed[host] {
+			notifiers = append(notifiers, n)
+		} else {
+			disallowed = append(disallowed, host)
+		}
+	}
+	if len(disallowed) > 0 {
+		config.HostError = fmt.Errorf("hosts [%s] in ConfigMap %s are not allowed by the operator, add them to %s of ConfigMap %s/%s",
+			strings.Join(disallowed, ", "), ConfigName, AllowedHostsKey, operatorNamespace, ConfigName)
+	}
+	config.Notifier = notifiers
+	return config, nil
+}
+
+// notifierHost returns the host the notifier connects to
+func notifierHost(n Notifier) string {
+	switch n := n.(type) {
+	case *SMTP:
+		if host, _, err := net.SplitHostPort(n.Addr); err == nil {
+			return host
+		}
+		return n.Addr
+	case *Webhook:
+		if u, err := url.Parse(n.URL); err == nil {
+			return u.Hostname()
+		}
+		return n.URL
+	}
+	return ""
+}
+
+// load returns the notification configuration in the namespace, without checking the hosts
+func load(ctx context.Context, c client.Client, namespace string) (*Config, error) {
+	key := types.NamespacedName{Name: ConfigName, Namespace: namespace}
+
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, key, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Credentials are optional
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var notifiers Multi
+	if addr := cm.Data[SMTPAddrKey]; addr != "" {
+		notifiers = append(notifiers, &SMTP{
+			Addr:     addr,
+			From:     cm.Data[SMTPFromKey],
+			Username: string(secret.Data[SMTPUsernameKey]),
+			Password: string(secret.Data[SMTPPasswordKey]),
+			Timeout:  SMTPTimeout,
+		})
+	}
+	if url := cm.Data[WebhookURLKey]; url != "" {
+		notifiers = append(notifiers, &Webhook{
+			URL:    url,
+			Token:  string(secret.Data[WebhookTokenKey]),
+			Client: &http.Client{Timeout: WebhookTimeout},
+		})
+	}
+
+	if len(notifiers) == 0 {
+		return nil, nil
+	}
+
+	config := &Config{Notifier: notifiers, LinkBase: cm.Data[LinkBaseKey]}
+	if config.Templates, config.TemplateError = ParseTemplates(cm.Data); config.TemplateError != nil {
+		config.TemplateError = templateError(config.TemplateError)
+	}
+	return config, nil
+}
@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLoad(t *testing.T) {
+	meta := metav1.ObjectMeta{Name: ConfigName, Namespace: "default"}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: meta,
+		Data: map[string]string{
+			SMTPAddrKey:   "smtp.tmax.co.kr:25",
+			SMTPFromKey:   "approval@tmax.co.kr",
+			WebhookURLKey: "http://chat.tmax.co.kr/hook",
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: meta,
+		Data:       map[string][]byte{SMTPUsernameKey: []byte("user"), WebhookTokenKey: []byte("token")},
+	}
+	c := fake.NewFakeClient(cm, secret)
+
+	config, err := Load(context.TODO(), c, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifiers, ok := config.Notifier.(Multi)
+	if !ok || len(notifiers) != 2 {
+		t.Fatalf("expected smtp and webhook notifiers, got %+v", config.Notifier)
+	}
+	if s := notifiers[0].(*SMTP); s.Addr != "smtp.tmax.co.kr:25" || s.Username != "user" {
+		t.Fatalf("unexpected smtp notifier: %+v", s)
+	}
+	if w := notifiers[1].(*Webhook); w.URL != "http://chat.tmax.co.kr/hook" || w.Token != "token" {
+		t.Fatalf("unexpected webhook notifier: %+v", w)
+	}
+
+	// Not configured
+	config, err = Load(context.TODO(), c, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != nil {
+		t.Fatalf("expected no notifier, got %+v", config)
+	}
+}
+
+func TestLoad_AllowedHosts(t *testing.T) {
+	data := map[string]string{
+		SMTPAddrKey:   "smtp.tmax.co.kr:25",
+		SMTPFromKey:   "approval@tmax.co.kr",
+		WebhookURLKey: "http://attacker.example.com/hook",
+	}
+	// Operator namespace is default, when not running in a cluster
+	operator := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigName, Namespace: "default"},
+		Data:       map[string]string{SMTPAddrKey: "smtp.tmax.co.kr:25", AllowedHostsKey: "smtp.tmax.co.kr"},
+	}
+	namespace := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigName, Namespace: "team"},
+		Data:       data,
+	}
+	c := fake.NewFakeClient(operator, namespace)
+
+	config, err := Load(context.TODO(), c, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifiers, ok := config.Notifier.(Multi)
+	if !ok || len(notifiers) != 1 {
+		t.Fatalf("expected only the smtp notifier, got %+v", config.Notifier)
+	}
+	if _, ok := notifiers[0].(*SMTP); !ok {
+		t.Fatalf("unexpected notifier: %+v", notifiers[0])
+	}
+	if config.HostError == nil {
+		t.Fatal("expected the webhook host to be reported")
+	}
+	if config.Mail() != nil {
+		t.Fatal("expected no mail for decision links from a namespace config")
+	}
+
+	config, err = Load(context.TODO(), c, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Mail() == nil {
+		t.Fatal("expected mail for decision links from the operator config")
+	}
+}
@@ -0,0 +1,124 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// EventType is the reason of a notification
+type EventType string
+
+const (
+	EventCreated   EventType = "Created"
+	EventReminder  EventType = "Reminder"
+	EventEscalated EventType = "Escalated"
+	EventDecided   EventType = "Decided"
+)
+
+// Notification is a message to the approvers of an approval
+type Notification struct {
+	Event    EventType
+	Approval *tmaxv1.Approval
+	// Recipients are the addresses of the users to be notified, keyed by user id
+	Recipients map[string]string
+	// Message describes the event, e.g., the decision made
+	Message string
+
+	// Pod is the pod requested the approval, if it exists
+	Pod *corev1.Pod
+	// Link is the link to the approval
+	Link string
+	// DecisionLinks are the one-click links to make each decision, if there is a single recipient.
+	// They are rendered only by the default rendering, never by the templates
+	DecisionLinks map[tmaxv1.DecisionType]string
+	// Templates render the notification. The default rendering is used if nil
+	Templates *Templates
+}
+
+// Notifier notifies the approvers of the events of approvals
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Multi notifies via every notifier, returning the errors of all of them
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, n *Notification) error {
+	var errs []string
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to notify: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Addresses returns the non-empty addresses of the recipients, sorted
+func (n *Notification) Addresses() []string {
+	var addrs []string
+	for _, addr := range n.Recipients {
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Render returns the subject, body and html body of the notification.
+// The default rendering is used for the templates not specified. The html body is empty if its template is not specified
+func (n *Notification) Render() (subject, body, html string, err error) {
+	if n.Templates != nil {
+		subject, body, html, err = n.Templates.render(&TemplateData{
+			Event:      n.Event,
+			Approval:   n.Approval,
+			Pod:        n.Pod,
+			Link:       n.Link,
+			Message:    n.Message,
+			Recipients: n.Recipients,
+		})
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+	if n.Templates == nil || n.Templates.Subject == nil {
+		subject = n.defaultSubject()
+	}
+	if n.Templates == nil || n.Templates.Body == nil {
+		body = n.defaultBody()
+	}
+	return subject, body, html, nil
+}
+
+func (n *Notification) defaultSubject() string {
+	return fmt.Sprintf("[Approval %s] %s/%s", n.Event, n.Approval.Namespace, n.Approval.Name)
+}
+
+func (n *Notification) defaultBody() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Approval %s/%s: %s\n", n.Approval.Namespace, n.Approval.Name, n.Event)
+	if n.Message != "" {
+		fmt.Fprintf(&b, "%s\n", n.Message)
+	}
+	if t := n.Approval.Status.Tally; t != nil {
+		fmt.Fprintf(&b, "Tally: %s\n", t.Summary)
+	}
+	if n.Link != "" {
+		fmt.Fprintf(&b, "Approval: %s\n", n.Link)
+	}
+	for _, d := range []tmaxv1.DecisionType{tmaxv1.DecisionApproved, tmaxv1.DecisionRejected} {
+		if link, exist := n.DecisionLinks[d]; exist {
+			fmt.Fprintf(&b, "%s: %s\n", d, link)
+		}
+	}
+	return b.String()
+}
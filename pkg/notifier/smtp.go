@@ -0,0 +1,149 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP sends the notifications as emails
+type SMTP struct {
+	// Addr is the address of the SMTP server, host:port
+	Addr string
+	From string
+
+	// Username and Password authenticate with PLAIN auth, if specified
+	Username string
+	Password string
+
+	// Timeout bounds dialing and the whole conversation with the server. Defaults to SMTPTimeout
+	Timeout time.Duration
+}
+
+func (s *SMTP) Notify(ctx context.Context, n *Notification) error {
+	to := n.Addresses()
+	if len(to) == 0 {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	subject, body, html, err := n.Render()
+	if err != nil {
+		return err
+	}
+
+	if err := s.send(ctx, host, auth, to, s.message(to, subject, body, html)); err != nil {
+		return fmt.Errorf("cannot send email via %s, err: %s", s.Addr, err.Error())
+	}
+	return nil
+}
+
+// send sends the mail as smtp.SendMail does, but gives up on the timeout or when the context is done
+func (s *SMTP) send(ctx context.Context, host string, auth smtp.Auth, to []string, msg []byte) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = SMTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// Unblock the conversation if the context is cancelled before the deadline
+	go func() {
+		<-ctx.Done()
+		_ = conn.SetDeadline(time.Now())
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message builds the mail. It is multipart/alternative of plain text and html, if html is not empty
+func (s *SMTP) message(to []string, subject, body, html string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.TrimSpace(subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if html == "" {
+		b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(crlf(body))
+		return []byte(b.String())
+	}
+
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=\"%s\"\r\n", mimeBoundary)
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "--%s\r\n", mimeBoundary)
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	b.WriteString(crlf(body))
+	fmt.Fprintf(&b, "\r\n--%s\r\n", mimeBoundary)
+	b.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n\r\n")
+	b.WriteString(crlf(html))
+	fmt.Fprintf(&b, "\r\n--%s--\r\n", mimeBoundary)
+	return []byte(b.String())
+}
+
+const mimeBoundary = "approval-notification-boundary"
+
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
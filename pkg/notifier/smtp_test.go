@@ -0,0 +1,166 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// smtpStandIn is a minimal SMTP server, which accepts a mail and records it
+type smtpStandIn struct {
+	listener net.Listener
+	rcpt     []string
+	data     chan string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStandIn{listener: l, data: make(chan string, 1)}
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.rcpt = append(s.rcpt, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data <- data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTP_Notify(t *testing.T) {
+	server := newSMTPStandIn(t)
+	defer server.listener.Close()
+
+	n := &SMTP{Addr: server.listener.Addr().String(), From: "approval@tmax.co.kr"}
+	notification := &Notification{
+		Event:      EventCreated,
+		Approval:   &tmaxv1.Approval{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		Recipients: map[string]string{"alice": "alice@tmax.co.kr", "bob": "bob@tmax.co.kr", "carol": ""},
+	}
+
+	if err := n.Notify(context.TODO(), notification); err != nil {
+		t.Fatal(err)
+	}
+
+	data := <-server.data
+	if len(server.rcpt) != 2 || server.rcpt[0] != "alice@tmax.co.kr" || server.rcpt[1] != "bob@tmax.co.kr" {
+		t.Fatalf("unexpected recipients: %v", server.rcpt)
+	}
+	if !strings.Contains(data, "Subject: [Approval Created] default/test") {
+		t.Fatalf("unexpected mail: %s", data)
+	}
+}
+
+func TestSMTP_NotifyTimeout(t *testing.T) {
+	// Server accepting connections without ever greeting
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	notification := &Notification{
+		Event:      EventCreated,
+		Approval:   &tmaxv1.Approval{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		Recipients: map[string]string{"alice": "alice@tmax.co.kr"},
+	}
+
+	tc := map[string]struct {
+		timeout time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+	}{
+		"timeout": {
+			timeout: 100 * time.Millisecond,
+			ctx:     func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+		},
+		"cancelled": {
+			timeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 100*time.Millisecond)
+			},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := c.ctx()
+			defer cancel()
+
+			n := &SMTP{Addr: l.Addr().String(), From: "approval@tmax.co.kr", Timeout: c.timeout}
+			start := time.Now()
+			if err := n.Notify(ctx, notification); err == nil {
+				t.Fatal("expected an error from the unresponsive server")
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("expected to give up in time, took %s", elapsed)
+			}
+		})
+	}
+}
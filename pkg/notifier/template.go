@@ -0,0 +1,116 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// ConfigMap keys of the templates
+const (
+	SubjectTemplateKey  = "subject.tmpl"
+	BodyTemplateKey     = "body.tmpl"
+	HTMLBodyTemplateKey = "body.html.tmpl"
+)
+
+// TemplateData is the data the templates are executed with
+type TemplateData struct {
+	Event      EventType
+	Approval   *tmaxv1.Approval
+	Pod        *corev1.Pod
+	Link       string
+	Message    string
+	Recipients map[string]string
+}
+
+// Templates render the notifications. Nil template means the default rendering
+type Templates struct {
+	Subject  *texttemplate.Template
+	Body     *texttemplate.Template
+	HTMLBody *htmltemplate.Template
+}
+
+// ParseTemplates parses the templates in the data of the ConfigMap, and executes them with a sample data
+// to catch the errors at load time rather than at the time of notification
+func ParseTemplates(data map[string]string) (*Templates, error) {
+	t := &Templates{}
+	var err error
+
+	if s, exist := data[SubjectTemplateKey]; exist {
+		if t.Subject, err = texttemplate.New(SubjectTemplateKey).Option("missingkey=error").Parse(s); err != nil {
+			return nil, err
+		}
+	}
+	if s, exist := data[BodyTemplateKey]; exist {
+		if t.Body, err = texttemplate.New(BodyTemplateKey).Option("missingkey=error").Parse(s); err != nil {
+			return nil, err
+		}
+	}
+	if s, exist := data[HTMLBodyTemplateKey]; exist {
+		if t.HTMLBody, err = htmltemplate.New(HTMLBodyTemplateKey).Option("missingkey=error").Parse(s); err != nil {
+			return nil, err
+		}
+	}
+
+	// Dry run
+	if _, _, _, err := t.render(sampleData()); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// render returns subject, body and html body rendered. Empty string is returned for the template not specified
+func (t *Templates) render(data *TemplateData) (subject, body, html string, err error) {
+	if t.Subject != nil {
+		buf := &bytes.Buffer{}
+		if err := t.Subject.Execute(buf, data); err != nil {
+			return "", "", "", err
+		}
+		subject = buf.String()
+	}
+	if t.Body != nil {
+		buf := &bytes.Buffer{}
+		if err := t.Body.Execute(buf, data); err != nil {
+			return "", "", "", err
+		}
+		body = buf.String()
+	}
+	if t.HTMLBody != nil {
+		buf := &bytes.Buffer{}
+		if err := t.HTMLBody.Execute(buf, data); err != nil {
+			return "", "", "", err
+		}
+		html = buf.String()
+	}
+	return subject, body, html, nil
+}
+
+func sampleData() *TemplateData {
+	approval := &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+		Spec: tmaxv1.ApprovalSpec{
+			ApproverPolicy: tmaxv1.ApproverPolicy{Threshold: 1, Users: map[string]string{"alice": "alice@tmax.co.kr"}},
+		},
+		Status: tmaxv1.ApprovalStatus{Tally: &tmaxv1.Tally{Required: 1, Summary: "0/1 approved, 0 rejected"}},
+	}
+	return &TemplateData{
+		Event:      EventCreated,
+		Approval:   approval,
+		Pod:        &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "sample-pod", Namespace: "default"}},
+		Link:       "https://approval.tmax.co.kr/approval/default/sample",
+		Message:    "sample message",
+		Recipients: approval.Spec.Users,
+	}
+}
+
+// templateError is the error of the templates, prefixed with the name of the ConfigMap
+func templateError(err error) error {
+	return fmt.Errorf("invalid template in ConfigMap %s, default template is used: %s", ConfigName, err.Error())
+}
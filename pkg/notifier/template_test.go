@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func TestParseTemplates(t *testing.T) {
+	tc := map[string]struct {
+		data    map[string]string
+		wantErr bool
+	}{
+		"valid": {
+			data: map[string]string{
+				SubjectTemplateKey:  "{{ .Event }}: {{ .Approval.Name }}",
+				BodyTemplateKey:     "Requested by {{ .Pod.Name }}, decide at {{ .Link }}",
+				HTMLBodyTemplateKey: "<a href=\"{{ .Link }}\">{{ .Approval.Name }}</a>",
+			},
+		},
+		"empty": {
+			data: map[string]string{},
+		},
+		"syntaxError": {
+			data:    map[string]string{SubjectTemplateKey: "{{ .Event "},
+			wantErr: true,
+		},
+		"unknownField": {
+			data:    map[string]string{BodyTemplateKey: "{{ .Approval.Unknown }}"},
+			wantErr: true,
+		},
+		"htmlUnknownField": {
+			data:    map[string]string{HTMLBodyTemplateKey: "{{ .Unknown }}"},
+			wantErr: true,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTemplates(c.data)
+			if c.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !c.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNotification_Render(t *testing.T) {
+	templates, err := ParseTemplates(map[string]string{
+		BodyTemplateKey:     "{{ .Approval.Name }} requested by {{ .Pod.Name }}",
+		HTMLBodyTemplateKey: "<b>{{ .Message }}</b>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &Notification{
+		Event:     EventCreated,
+		Approval:  &tmaxv1.Approval{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		Pod:       &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "task-pod"}},
+		Message:   "<approve>",
+		Templates: templates,
+	}
+
+	subject, body, html, err := n.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "[Approval Created] default/test" {
+		t.Fatalf("expected default subject, got %s", subject)
+	}
+	if body != "test requested by task-pod" {
+		t.Fatalf("unexpected body: %s", body)
+	}
+	if !strings.Contains(html, "&lt;approve&gt;") {
+		t.Fatalf("expected html to be escaped, got %s", html)
+	}
+}
@@ -0,0 +1,89 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// Webhook posts the notifications as json to the url
+type Webhook struct {
+	URL string
+	// Token is sent as a bearer token, if specified
+	Token string
+
+	Client *http.Client
+}
+
+// WebhookPayload is the json body posted by the Webhook notifier
+type WebhookPayload struct {
+	Event      EventType         `json:"event"`
+	Namespace  string            `json:"namespace"`
+	Name       string            `json:"name"`
+	Recipients map[string]string `json:"recipients"`
+	Subject    string            `json:"subject"`
+	Message    string            `json:"message"`
+	HTML       string            `json:"html,omitempty"`
+	Link       string            `json:"link,omitempty"`
+
+	Conditions tmaxv1.Conditions `json:"conditions,omitempty"`
+	Tally      *tmaxv1.Tally     `json:"tally,omitempty"`
+}
+
+func (w *Webhook) Notify(ctx context.Context, n *Notification) error {
+	// Decision links are never posted, as the webhook may be any endpoint
+	plain := *n
+	plain.DecisionLinks = nil
+	subject, body, html, err := plain.Render()
+	if err != nil {
+		return err
+	}
+
+	payload := WebhookPayload{
+		Event:      n.Event,
+		Namespace:  n.Approval.Namespace,
+		Name:       n.Approval.Name,
+		Recipients: n.Recipients,
+		Subject:    subject,
+		Message:    body,
+		HTML:       html,
+		Link:       n.Link,
+		Conditions: n.Approval.Status.Conditions,
+		Tally:      n.Approval.Status.Tally,
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Token)
+	}
+
+	httpClient := w.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot post notification to %s, err: %s", w.URL, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBytes, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("webhook %s replied with status %d: %s", w.URL, resp.StatusCode, string(respBytes))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func TestWebhook_Notify(t *testing.T) {
+	var got WebhookPayload
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	n := &Webhook{URL: server.URL, Token: "token"}
+	notification := &Notification{
+		Event:      EventDecided,
+		Approval:   &tmaxv1.Approval{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		Recipients: map[string]string{"alice": "alice@tmax.co.kr"},
+		Message:    "decision Approved is made",
+	}
+
+	if err := n.Notify(context.TODO(), notification); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer token" {
+		t.Fatalf("unexpected authorization header: %s", auth)
+	}
+	if got.Event != EventDecided || got.Namespace != "default" || got.Name != "test" || got.Recipients["alice"] != "alice@tmax.co.kr" {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+}
+
+func TestWebhook_NotifyError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := &Webhook{URL: server.URL}
+	notification := &Notification{
+		Event:    EventDecided,
+		Approval: &tmaxv1.Approval{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+	}
+	if err := n.Notify(context.TODO(), notification); err == nil {
+		t.Fatal("expected error for the status 500")
+	}
+}
+
+func TestWebhook_NotifyNoDecisionLinks(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	n := &Webhook{URL: server.URL}
+	notification := &Notification{
+		Event:         EventCreated,
+		Approval:      &tmaxv1.Approval{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		Recipients:    map[string]string{"alice": "alice@tmax.co.kr"},
+		DecisionLinks: map[tmaxv1.DecisionType]string{tmaxv1.DecisionApproved: "https://approval.tmax.co.kr/decide?token=secret"},
+	}
+
+	if err := n.Notify(context.TODO(), notification); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "token=secret") {
+		t.Fatalf("expected no decision link in the payload, got %s", body)
+	}
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"approval-operator/pkg/apis"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/callback"
+	approvalWebhook "approval-operator/pkg/webhook/approval"
+)
+
+func (s *Server) approvalCreator(w http.ResponseWriter, r *http.Request) {
+	var m apis.PostApprovalMessage
+	err := json.NewDecoder(r.Body).Decode(&m)
+	if err != nil {
+		log.Error(err, "Cannot decode the message")
+		replyError(w, http.StatusBadRequest, fmt.Errorf("cannot decode the message: %s", err.Error()))
+		return
+	}
+
+	// Only a service account of the namespace can request an approval in the namespace
+	if code, err := authorize(s.client, r, m.Namespace); err != nil {
+		replyError(w, code, err)
+		return
+	}
+
+	// The approval is owned by the requesting pod, so that it's cleaned up with the pod
+	pod := &corev1.Pod{}
+	if err := s.client.Get(context.TODO(), types.NamespacedName{Name: m.PodName, Namespace: m.Namespace}, pod); err != nil {
+		if k8serrors.IsNotFound(err) {
+			replyError(w, http.StatusBadRequest, fmt.Errorf("requesting pod %s is not found", m.PodName))
+			return
+		}
+		replyError(w, statusCode(err), err)
+		return
+	}
+	if pod.Status.PodIP != m.PodIP {
+		replyError(w, http.StatusBadRequest, fmt.Errorf("podIP(%s) is not the IP of pod %s", m.PodIP, m.PodName))
+		return
+	}
+
+	labels := make(map[string]string)
+	for k := range m.Users {
+		labels[k] = ""
+	}
+	for _, stage := range m.Stages {
+		for k := range stage.Users {
+			labels[k] = ""
+		}
+	}
+	for _, step := range m.Escalations {
+		for k := range step.Users {
+			labels[k] = ""
+		}
+	}
+
+	newApproval := &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-", m.PodName),
+			Namespace:    m.Namespace,
+			Labels:       labels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       pod.Name,
+				UID:        pod.UID,
+			}},
+		},
+		Spec: tmaxv1.ApprovalSpec{
+			PodIP:      m.PodIP,
+			AccessPath: m.AccessPath,
+			Port:       m.Port,
+			ApproverPolicy: tmaxv1.ApproverPolicy{
+				Threshold:    m.Threshold,
+				Users:        m.Users,
+				Groups:       m.Groups,
+				AccessReview: m.AccessReview,
+				Weights:      m.Weights,
+				Quorum:       m.Quorum,
+
+				RejectionRule:   m.RejectionRule,
+				RejectThreshold: m.RejectThreshold,
+			},
+			Stages: m.Stages,
+			Scheme: m.Scheme,
+
+			Timeout:         m.Timeout,
+			DefaultDecision: m.DefaultDecision,
+
+			RequireRejectionReason: m.RequireRejectionReason,
+			Escalations:            m.Escalations,
+			ReminderInterval:       m.ReminderInterval,
+		},
+	}
+
+	if err := approvalWebhook.Validate(newApproval); err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The task generates its own key and sends the certificate request, if https is used
+	if newApproval.IsTLS() {
+		if _, err := callback.ParseCertificateRequest(m.CSR); err != nil {
+			replyError(w, http.StatusBadRequest, fmt.Errorf("invalid csr: %s", err.Error()))
+			return
+		}
+	}
+
+	// If request id is given, the name is derived from it so that retried requests end up with the same approval
+	if m.RequestID != "" {
+		newApproval.GenerateName = ""
+		newApproval.Name = fmt.Sprintf("%s-%s", m.PodName, requestIDHash(m.RequestID))
+		newApproval.Annotations = map[string]string{tmaxv1.RequestIDAnnotation: m.RequestID}
+	}
+
+	// Approval created just before may not be in the cache yet, so use direct client
+	c := s.directClient
+
+	code := http.StatusCreated
+	err = c.Create(context.TODO(), newApproval)
+	if err != nil && m.RequestID != "" && k8serrors.IsAlreadyExists(err) {
+		// Return the approval created by the previous request
+		key := types.NamespacedName{Name: newApproval.Name, Namespace: newApproval.Namespace}
+		newApproval = &tmaxv1.Approval{}
+		err = c.Get(context.TODO(), key, newApproval)
+		if err == nil && newApproval.Annotations[tmaxv1.RequestIDAnnotation] != m.RequestID {
+			replyError(w, http.StatusConflict, fmt.Errorf("approval %s already exists for another request", newApproval.Name))
+			return
+		}
+		// The decision of the ended approval is not sent again, so the retried request cannot wait for it
+		if err == nil && newApproval.Status.IsFinal() {
+			replyError(w, http.StatusConflict, fmt.Errorf("approval %s for the request already ended", newApproval.Name))
+			return
+		}
+		code = http.StatusOK
+	}
+	if err != nil {
+		log.Error(err, "Cannot create approval")
+		replyError(w, statusCode(err), err)
+		return
+	}
+
+	secret, err := callback.EnsureSecret(context.TODO(), c, newApproval)
+	if err != nil {
+		log.Error(err, "Cannot create callback secret")
+		replyError(w, statusCode(err), err)
+		return
+	}
+
+	resp := apis.PostApprovalResponse{
+		PostApprovalMessage: m,
+		Name:                newApproval.Name,
+		UID:                 newApproval.UID,
+		CallbackKey:         secret.Data[callback.KeyField],
+	}
+
+	// Issue the certificate of the task with the CA of the operator
+	if newApproval.IsTLS() {
+		ca, err := callback.EnsureCA(context.TODO(), c)
+		if err != nil {
+			log.Error(err, "Cannot get callback CA")
+			replyError(w, statusCode(err), err)
+			return
+		}
+		if resp.TLSCert, err = ca.Issue(newApproval, m.CSR); err != nil {
+			log.Error(err, "Cannot issue certificate")
+			replyError(w, statusCode(err), err)
+			return
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err = enc.Encode(resp)
+	if err != nil {
+		log.Error(err, "Cannot reply request")
+		return
+	}
+}
+
+// requestIDHash returns a short hash of the request id, which can be used as a part of object name
+func requestIDHash(requestID string) string {
+	sum := sha256.Sum256([]byte(requestID))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+func (s *Server) approvalGetter(w http.ResponseWriter, r *http.Request) {
+	instance, code, err := getApproval(s.client, r)
+	if err != nil {
+		replyError(w, code, err)
+		return
+	}
+
+	replyApproval(w, instance)
+}
+
+// approvalDeleter cancels the approval by deleting it. Approvals already ended cannot be deleted
+func (s *Server) approvalDeleter(w http.ResponseWriter, r *http.Request) {
+	instance, code, err := getApproval(s.client, r)
+	if err != nil {
+		replyError(w, code, err)
+		return
+	}
+
+	if instance.Status.IsFinal() {
+		replyError(w, http.StatusConflict, fmt.Errorf("approval %s/%s already ended", instance.Namespace, instance.Name))
+		return
+	}
+
+	// Delete only the approval we've checked, not the one created after with the same name
+	if err := s.client.Delete(context.TODO(), instance, client.Preconditions{UID: &instance.UID}); err != nil {
+		log.Error(err, "Cannot delete approval")
+		replyError(w, statusCode(err), err)
+		return
+	}
+
+	replyApproval(w, instance)
+}
+
+// getApproval gets the approval specified in the path, after checking the request is authorized for its namespace
+func getApproval(c client.Client, r *http.Request) (*tmaxv1.Approval, int, error) {
+	vars := mux.Vars(r)
+	key := types.NamespacedName{Namespace: vars["namespace"], Name: vars["name"]}
+
+	if code, err := authorize(c, r, key.Namespace); err != nil {
+		return nil, code, err
+	}
+
+	instance := &tmaxv1.Approval{}
+	if err := c.Get(context.TODO(), key, instance); err != nil {
+		return nil, statusCode(err), err
+	}
+
+	return instance, http.StatusOK, nil
+}
+
+func replyApproval(w http.ResponseWriter, instance *tmaxv1.Approval) {
+	resp := apis.GetApprovalResponse{
+		Name:       instance.Name,
+		Namespace:  instance.Namespace,
+		UID:        instance.UID,
+		Conditions: instance.Status.Conditions,
+		Approvers:  instance.Status.Approvers,
+
+		CurrentStage: instance.Status.CurrentStage,
+		Stages:       instance.Status.Stages,
+	}
+
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+	if err := enc.Encode(resp); err != nil {
+		log.Error(err, "Cannot reply request")
+	}
+}
+
+// authorize checks if the request is made by a service account of the namespace.
+// It returns the http status code to reply with, if it is not authorized
+func authorize(c client.Client, r *http.Request, namespace string) (int, error) {
+	ns, err := authenticateRequest(c, r)
+	if err != nil {
+		log.Info("Authentication failed", "error", err.Error())
+		return http.StatusUnauthorized, err
+	}
+	if ns != namespace {
+		return http.StatusForbidden, fmt.Errorf("service account of namespace %s cannot access approval in namespace %s", ns, namespace)
+	}
+	return http.StatusOK, nil
+}
+
+// authenticateRequest reviews the bearer token of the request and returns the namespace of the service account
+func authenticateRequest(c client.Client, r *http.Request) (string, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return "", errors.New("bearer token is not given")
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	if err := c.Create(context.TODO(), review); err != nil {
+		return "", err
+	}
+	if !review.Status.Authenticated {
+		return "", fmt.Errorf("token is not authenticated: %s", review.Status.Error)
+	}
+
+	// Username of a service account is system:serviceaccount:<namespace>:<name>
+	parts := strings.Split(review.Status.User.Username, ":")
+	if len(parts) != 4 || parts[0] != "system" || parts[1] != "serviceaccount" {
+		return "", fmt.Errorf("user(%s) is not a service account", review.Status.User.Username)
+	}
+
+	return parts[2], nil
+}
+
+// statusCode returns the http status code of the error from the api server, or 500 if it is not the one
+func statusCode(err error) int {
+	if status, ok := err.(k8serrors.APIStatus); ok && status.Status().Code != 0 {
+		return int(status.Status().Code)
+	}
+	return http.StatusInternalServerError
+}
+
+func replyError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(apis.ErrorMessage{Error: err.Error()}); err != nil {
+		log.Error(err, "Cannot reply request")
+	}
+}
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"approval-operator/pkg/apis"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// reviewClient answers TokenReviews with the users of the tokens, and checks the preconditions of deletions as
+// the api server does
+type reviewClient struct {
+	client.Client
+	users map[string]string
+
+	// beforeDelete is called before deleting an object, e.g., to replace it
+	beforeDelete func()
+}
+
+func (c *reviewClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	if c.beforeDelete != nil {
+		c.beforeDelete()
+	}
+
+	delOpts := &client.DeleteOptions{}
+	delOpts.ApplyOptions(opts)
+	if delOpts.Preconditions != nil && delOpts.Preconditions.UID != nil {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		current := obj.DeepCopyObject()
+		if err := c.Client.Get(ctx, types.NamespacedName{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}, current); err != nil {
+			return err
+		}
+		currentAccessor, err := meta.Accessor(current)
+		if err != nil {
+			return err
+		}
+		if currentAccessor.GetUID() != *delOpts.Preconditions.UID {
+			return k8serrors.NewConflict(tmaxv1.SchemeGroupVersion.WithResource("approvals").GroupResource(), accessor.GetName(),
+				fmt.Errorf("precondition failed: UID in precondition: %s, UID in object meta: %s", *delOpts.Preconditions.UID, currentAccessor.GetUID()))
+		}
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *reviewClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	review, ok := obj.(*authenticationv1.TokenReview)
+	if !ok {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	if user, exist := c.users[review.Spec.Token]; exist {
+		review.Status.Authenticated = true
+		review.Status.User.Username = user
+	} else {
+		review.Status.Error = "invalid token"
+	}
+	return nil
+}
+
+// newTestServer returns a server with a fake client holding the objects.
+// Token "default-token" is of a service account in namespace default, "other-token" is of namespace other
+// and "user-token" is of a user, not a service account
+func newTestServer(t *testing.T, objs ...runtime.Object) *Server {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmaxv1.SchemeBuilder.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &reviewClient{
+		Client: fake.NewFakeClientWithScheme(s, objs...),
+		users: map[string]string{
+			"default-token": "system:serviceaccount:default:pipeline",
+			"other-token":   "system:serviceaccount:other:pipeline",
+			"user-token":    "alice",
+		},
+	}
+	return &Server{client: c, directClient: c}
+}
+
+// serve sends the request to the router of the server and returns the response
+func serve(s *Server, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router().ServeHTTP(w, req)
+	return w
+}
+
+func testPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "task", Namespace: "default", UID: "pod-uid"},
+		Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+	}
+}
+
+func testMessage() apis.PostApprovalMessage {
+	return apis.PostApprovalMessage{
+		Namespace:  "default",
+		PodName:    "task",
+		PodIP:      "10.0.0.1",
+		Threshold:  1,
+		AccessPath: "/",
+		Port:       10203,
+		Users:      map[string]string{"alice": "alice@tmax.co.kr"},
+		RequestID:  "request-1",
+	}
+}
+
+func TestApprovalCreator(t *testing.T) {
+	invalid := testMessage()
+	invalid.Threshold = 2
+
+	otherPod := testMessage()
+	otherPod.PodName = "other"
+
+	otherIP := testMessage()
+	otherIP.PodIP = "10.0.0.2"
+
+	tc := map[string]struct {
+		token   string
+		message apis.PostApprovalMessage
+		code    int
+	}{
+		"created":           {token: "default-token", message: testMessage(), code: http.StatusCreated},
+		"noToken":           {message: testMessage(), code: http.StatusUnauthorized},
+		"invalidToken":      {token: "invalid-token", message: testMessage(), code: http.StatusUnauthorized},
+		"notServiceAccount": {token: "user-token", message: testMessage(), code: http.StatusUnauthorized},
+		"otherNamespace":    {token: "other-token", message: testMessage(), code: http.StatusForbidden},
+		"invalidPolicy":     {token: "default-token", message: invalid, code: http.StatusBadRequest},
+		"podNotFound":       {token: "default-token", message: otherPod, code: http.StatusBadRequest},
+		"podIPMismatch":     {token: "default-token", message: otherIP, code: http.StatusBadRequest},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, testPod())
+			w := serve(s, "POST", "/approval", c.token, c.message)
+			if w.Code != c.code {
+				t.Fatalf("expected %d, got %d: %s", c.code, w.Code, w.Body.String())
+			}
+
+			approvals := &tmaxv1.ApprovalList{}
+			if err := s.client.List(context.TODO(), approvals); err != nil {
+				t.Fatal(err)
+			}
+			if c.code != http.StatusCreated {
+				if len(approvals.Items) != 0 {
+					t.Fatalf("expected no approval to be created, got %d", len(approvals.Items))
+				}
+				errMsg := apis.ErrorMessage{}
+				if err := json.NewDecoder(w.Body).Decode(&errMsg); err != nil || errMsg.Error == "" {
+					t.Fatalf("expected an error message, got %s", w.Body.String())
+				}
+				return
+			}
+
+			resp := apis.PostApprovalResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(approvals.Items) != 1 || approvals.Items[0].Name != resp.Name || len(resp.CallbackKey) == 0 {
+				t.Fatalf("expected the approval %s to be created with callback key, got %+v", resp.Name, approvals.Items)
+			}
+			if ref := approvals.Items[0].OwnerReferences; len(ref) != 1 || ref[0].UID != "pod-uid" {
+				t.Fatalf("expected the approval to be owned by the pod, got %+v", ref)
+			}
+		})
+	}
+}
+
+func testApproval(conditionType tmaxv1.ConditionType) *tmaxv1.Approval {
+	return &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "approval-uid"},
+		Status: tmaxv1.ApprovalStatus{
+			Conditions: tmaxv1.Conditions{{Type: conditionType, Status: corev1.ConditionTrue}},
+			Approvers:  []tmaxv1.Approver{{UserID: "alice", Decision: tmaxv1.DecisionApproved}},
+		},
+	}
+}
+
+func TestApprovalGetter(t *testing.T) {
+	tc := map[string]struct {
+		token string
+		path  string
+		code  int
+	}{
+		"found":          {token: "default-token", path: "/approval/default/test", code: http.StatusOK},
+		"notFound":       {token: "default-token", path: "/approval/default/none", code: http.StatusNotFound},
+		"noToken":        {path: "/approval/default/test", code: http.StatusUnauthorized},
+		"otherNamespace": {token: "other-token", path: "/approval/default/test", code: http.StatusForbidden},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, testApproval(tmaxv1.ConditionWaiting))
+			w := serve(s, "GET", c.path, c.token, nil)
+			if w.Code != c.code {
+				t.Fatalf("expected %d, got %d: %s", c.code, w.Code, w.Body.String())
+			}
+			if c.code != http.StatusOK {
+				return
+			}
+
+			resp := apis.GetApprovalResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Name != "test" || resp.UID != "approval-uid" || len(resp.Conditions) != 1 || len(resp.Approvers) != 1 {
+				t.Fatalf("unexpected response %+v", resp)
+			}
+		})
+	}
+}
+
+func TestApprovalDeleter(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+
+	tc := map[string]struct {
+		approval *tmaxv1.Approval
+		token    string
+		replaced bool
+		code     int
+		deleted  bool
+	}{
+		"waiting":        {approval: testApproval(tmaxv1.ConditionWaiting), token: "default-token", code: http.StatusOK, deleted: true},
+		"ended":          {approval: testApproval(tmaxv1.ConditionApproved), token: "default-token", code: http.StatusConflict},
+		"otherNamespace": {approval: testApproval(tmaxv1.ConditionWaiting), token: "other-token", code: http.StatusForbidden},
+		"replaced":       {approval: testApproval(tmaxv1.ConditionWaiting), token: "default-token", replaced: true, code: http.StatusConflict},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, c.approval)
+
+			// Another approval is created with the same name, after the approval is checked
+			if c.replaced {
+				rc := s.client.(*reviewClient)
+				rc.beforeDelete = func() {
+					rc.beforeDelete = nil
+					if err := rc.Client.Delete(context.TODO(), c.approval.DeepCopy()); err != nil {
+						t.Fatal(err)
+					}
+					newer := testApproval(tmaxv1.ConditionWaiting)
+					newer.UID = "newer-uid"
+					if err := rc.Client.Create(context.TODO(), newer); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			w := serve(s, "DELETE", "/approval/default/test", c.token, nil)
+			if w.Code != c.code {
+				t.Fatalf("expected %d, got %d: %s", c.code, w.Code, w.Body.String())
+			}
+
+			err := s.client.Get(context.TODO(), key, &tmaxv1.Approval{})
+			if c.deleted && !k8serrors.IsNotFound(err) {
+				t.Fatalf("expected the approval to be deleted, got %v", err)
+			}
+			if !c.deleted && err != nil {
+				t.Fatalf("expected the approval to remain, got %v", err)
+			}
+		})
+	}
+}
+
+func TestApprovalCreator_RequestID(t *testing.T) {
+	s := newTestServer(t, testPod())
+
+	first := serve(s, "POST", "/approval", "default-token", testMessage())
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+	created := apis.PostApprovalResponse{}
+	if err := json.NewDecoder(first.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	// Retried request returns the same approval
+	retried := serve(s, "POST", "/approval", "default-token", testMessage())
+	if retried.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, retried.Code, retried.Body.String())
+	}
+	resp := apis.PostApprovalResponse{}
+	if err := json.NewDecoder(retried.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != created.Name || resp.UID != created.UID {
+		t.Fatalf("expected the approval %s to be returned, got %s", created.Name, resp.Name)
+	}
+
+	approvals := &tmaxv1.ApprovalList{}
+	if err := s.client.List(context.TODO(), approvals); err != nil {
+		t.Fatal(err)
+	}
+	if len(approvals.Items) != 1 {
+		t.Fatalf("expected a single approval, got %d", len(approvals.Items))
+	}
+
+	// Retried request is refused, once the approval ended
+	approvals.Items[0].Status.Conditions = tmaxv1.Conditions{{Type: tmaxv1.ConditionApproved, Status: corev1.ConditionTrue}}
+	if err := s.client.Status().Update(context.TODO(), &approvals.Items[0]); err != nil {
+		t.Fatal(err)
+	}
+	ended := serve(s, "POST", "/approval", "default-token", testMessage())
+	if ended.Code != http.StatusConflict {
+		t.Fatalf("expected %d, got %d: %s", http.StatusConflict, ended.Code, ended.Body.String())
+	}
+	if err := s.client.Get(context.TODO(), types.NamespacedName{Name: created.Name, Namespace: "default"}, &approvals.Items[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Approval of the name is taken by another request
+	approvals.Items[0].Annotations[tmaxv1.RequestIDAnnotation] = "request-2"
+	if err := s.client.Update(context.TODO(), &approvals.Items[0]); err != nil {
+		t.Fatal(err)
+	}
+	conflict := serve(s, "POST", "/approval", "default-token", testMessage())
+	if conflict.Code != http.StatusConflict {
+		t.Fatalf("expected %d, got %d: %s", http.StatusConflict, conflict.Code, conflict.Body.String())
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/token"
+	approvalWebhook "approval-operator/pkg/webhook/approval"
+)
+
+var decisionPage = template.Must(template.New("decide").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Approval {{ .Namespace }}/{{ .Name }}</title></head>
+<body>
+<h2>Approval {{ .Namespace }}/{{ .Name }}</h2>
+{{- if .Error }}
+<p>{{ .Error }}</p>
+{{- else if .Done }}
+<p>{{ .Decision }} by {{ .User }} is recorded.</p>
+{{- else }}
+<p>{{ .User }}, do you want to make the decision <b>{{ .Decision }}</b>?</p>
+<form method="POST" action="decide">
+<input type="hidden" name="token" value="{{ .Token }}">
+{{- if eq .Decision "Rejected" }}
+<p><label>Reason <input type="text" name="reason"{{ if .ReasonRequired }} required{{ end }}></label></p>
+{{- end }}
+<input type="submit" value="{{ .Decision }}">
+</form>
+{{- end }}
+</body>
+</html>
+`))
+
+type decisionPageData struct {
+	Namespace string
+	Name      string
+	User      string
+	Decision  tmaxv1.DecisionType
+	Token     string
+	// ReasonRequired is true if the rejection should be justified
+	ReasonRequired bool
+	Done           bool
+	Error          string
+}
+
+// decisionConfirmer shows the decision of the token to be confirmed.
+// The decision is not made by GET, so that link previews and scanners do not redeem the token
+func (s *Server) decisionConfirmer(w http.ResponseWriter, r *http.Request) {
+	t := r.URL.Query().Get("token")
+	claims, code, err := s.parseToken(t)
+	if err != nil {
+		replyDecisionPage(w, code, &decisionPageData{Error: err.Error()})
+		return
+	}
+
+	data := &decisionPageData{
+		Namespace: claims.Namespace,
+		Name:      claims.Name,
+		User:      claims.User,
+		Decision:  claims.Decision,
+		Token:     t,
+	}
+	instance := &tmaxv1.Approval{}
+	if err := s.directClient.Get(context.TODO(), types.NamespacedName{Namespace: claims.Namespace, Name: claims.Name}, instance); err == nil {
+		data.ReasonRequired = instance.Spec.RequireRejectionReason
+	}
+	replyDecisionPage(w, http.StatusOK, data)
+}
+
+// decisionRedeemer redeems the token, making the decision as the user of the token.
+// The decision is checked as the validating webhook checks the user's own decision, then the operator records it
+// and marks the token as used in a single update, so that a denied decision does not use up the token
+func (s *Server) decisionRedeemer(w http.ResponseWriter, r *http.Request) {
+	claims, code, err := s.parseToken(r.FormValue("token"))
+	if err != nil {
+		replyDecisionPage(w, code, &decisionPageData{Error: err.Error()})
+		return
+	}
+	data := &decisionPageData{Namespace: claims.Namespace, Name: claims.Name, User: claims.User, Decision: claims.Decision}
+
+	if code, err := s.redeemToken(claims, r.FormValue("reason")); err != nil {
+		if code >= http.StatusInternalServerError {
+			log.Error(err, "Cannot make decision", "user", claims.User)
+		}
+		data.Error = err.Error()
+		replyDecisionPage(w, code, data)
+		return
+	}
+
+	data.Done = true
+	replyDecisionPage(w, http.StatusOK, data)
+}
+
+// parseToken verifies the token, returning the http status code to reply with if it is not valid
+func (s *Server) parseToken(t string) (*token.Claims, int, error) {
+	if t == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("token is not given")
+	}
+	key, err := token.EnsureKey(context.TODO(), s.directClient)
+	if err != nil {
+		log.Error(err, "Cannot get token key")
+		return nil, http.StatusInternalServerError, err
+	}
+	claims, err := token.Parse(key, t, time.Now())
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+	return claims, http.StatusOK, nil
+}
+
+// redeemToken sets the decision of the token to the approval and marks the token as used,
+// if the approval is still in progress and the user of the token is permitted the decision
+func (s *Server) redeemToken(claims *token.Claims, reason string) (int, error) {
+	code := http.StatusOK
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		instance := &tmaxv1.Approval{}
+		if err := s.directClient.Get(context.TODO(), types.NamespacedName{Namespace: claims.Namespace, Name: claims.Name}, instance); err != nil {
+			code = statusCode(err)
+			return err
+		}
+
+		switch {
+		case instance.UID != claims.UID:
+			code = http.StatusGone
+			return fmt.Errorf("approval %s/%s of the token no longer exists", claims.Namespace, claims.Name)
+		case instance.Status.IsFinal():
+			code = http.StatusConflict
+			return fmt.Errorf("approval %s/%s already ended", claims.Namespace, claims.Name)
+		case hasToken(instance.Status.RedeemedTokens, claims.ID):
+			code = http.StatusConflict
+			return fmt.Errorf("token is already used")
+		}
+
+		old := instance.DeepCopy()
+		// The decision of a token is the user's own, neither on behalf of another user nor through a group
+		instance.Status.SetDecision(tmaxv1.Approver{
+			UserID:       claims.User,
+			Decision:     claims.Decision,
+			ApprovedTime: metav1.NewTime(time.Now()),
+			Reason:       reason,
+		})
+		if err := approvalWebhook.Validate(instance); err != nil {
+			code = http.StatusBadRequest
+			return err
+		}
+		if err := approvalWebhook.Authenticate(context.TODO(), s.directClient, instance, old, authenticationv1.UserInfo{Username: claims.User}); err != nil {
+			code = http.StatusForbidden
+			return err
+		}
+
+		instance.Status.RedeemedTokens = append(instance.Status.RedeemedTokens, claims.ID)
+		if err := s.directClient.Status().Update(context.TODO(), instance); err != nil {
+			code = statusCode(err)
+			return err
+		}
+		return nil
+	})
+	return code, err
+}
+
+func hasToken(tokens []string, id string) bool {
+	for _, t := range tokens {
+		if t == id {
+			return true
+		}
+	}
+	return false
+}
+
+func replyDecisionPage(w http.ResponseWriter, code int, data *decisionPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := decisionPage.Execute(w, data); err != nil {
+		log.Error(err, "Cannot reply request")
+	}
+}
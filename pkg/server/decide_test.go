@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+	"approval-operator/pkg/token"
+)
+
+func decisionApproval() *tmaxv1.Approval {
+	return &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "approval-uid"},
+		Spec: tmaxv1.ApprovalSpec{
+			PodIP:      "10.0.0.1",
+			Port:       10203,
+			AccessPath: "/",
+			ApproverPolicy: tmaxv1.ApproverPolicy{
+				Threshold: 2,
+				Users:     map[string]string{"alice": "alice@tmax.co.kr", "bob": "bob@tmax.co.kr"},
+				Groups:    []tmaxv1.GroupApprover{{Name: "sre", Threshold: 1}},
+			},
+		},
+		Status: tmaxv1.ApprovalStatus{
+			Conditions: tmaxv1.Conditions{{Type: tmaxv1.ConditionWaiting, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+// signToken returns the token for the user to make the decision on the approval
+func signToken(t *testing.T, s *Server, approval *tmaxv1.Approval, user string, decision tmaxv1.DecisionType) (string, *token.Claims) {
+	key, err := token.EnsureKey(context.TODO(), s.directClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := token.NewClaims(approval, user, decision, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := token.Sign(key, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed, claims
+}
+
+// redeem posts the confirmation form of the token
+func redeem(s *Server, t, reason string) *httptest.ResponseRecorder {
+	form := url.Values{"token": {t}}
+	if reason != "" {
+		form.Set("reason", reason)
+	}
+	req := httptest.NewRequest("POST", "/decide", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.router().ServeHTTP(w, req)
+	return w
+}
+
+func decidedApproval(t *testing.T, s *Server) *tmaxv1.Approval {
+	instance := &tmaxv1.Approval{}
+	if err := s.client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "test"}, instance); err != nil {
+		t.Fatal(err)
+	}
+	return instance
+}
+
+func TestDecisionRedeemer(t *testing.T) {
+	ended := decisionApproval()
+	ended.Status.Conditions = tmaxv1.Conditions{{Type: tmaxv1.ConditionApproved, Status: corev1.ConditionTrue}}
+
+	reasonRequired := decisionApproval()
+	reasonRequired.Spec.RequireRejectionReason = true
+
+	throughGroup := decisionApproval()
+	throughGroup.Status.Approvers = []tmaxv1.Approver{{UserID: "bob", Decision: tmaxv1.DecisionRejected, Group: "sre"}}
+
+	tc := map[string]struct {
+		approval *tmaxv1.Approval
+		user     string
+		decision tmaxv1.DecisionType
+		uid      types.UID
+		reason   string
+		code     int
+		redeemed bool
+	}{
+		"approve":             {approval: decisionApproval(), user: "bob", decision: tmaxv1.DecisionApproved, code: http.StatusOK, redeemed: true},
+		"reject":              {approval: decisionApproval(), user: "bob", decision: tmaxv1.DecisionRejected, code: http.StatusOK, redeemed: true},
+		"rejectWithReason":    {approval: reasonRequired, user: "bob", decision: tmaxv1.DecisionRejected, reason: "SecurityConcern", code: http.StatusOK, redeemed: true},
+		"rejectWithoutReason": {approval: reasonRequired, user: "bob", decision: tmaxv1.DecisionRejected, code: http.StatusBadRequest},
+		"ownDecision":         {approval: throughGroup, user: "bob", decision: tmaxv1.DecisionApproved, code: http.StatusOK, redeemed: true},
+		"notApprover":         {approval: decisionApproval(), user: "mallory", decision: tmaxv1.DecisionApproved, code: http.StatusForbidden},
+		"wrongUID":            {approval: decisionApproval(), user: "bob", decision: tmaxv1.DecisionApproved, uid: "old-uid", code: http.StatusGone},
+		"ended":               {approval: ended, user: "bob", decision: tmaxv1.DecisionApproved, code: http.StatusConflict},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, c.approval)
+			tokenOf := c.approval.DeepCopy()
+			if c.uid != "" {
+				tokenOf.UID = c.uid
+			}
+			signed, claims := signToken(t, s, tokenOf, c.user, c.decision)
+
+			w := redeem(s, signed, c.reason)
+			if w.Code != c.code {
+				t.Fatalf("expected %d, got %d: %s", c.code, w.Code, w.Body.String())
+			}
+
+			instance := decidedApproval(t, s)
+			if redeemed := hasToken(instance.Status.RedeemedTokens, claims.ID); redeemed != c.redeemed {
+				t.Fatalf("expected the token to be redeemed: %t, got %v", c.redeemed, instance.Status.RedeemedTokens)
+			}
+			if !c.redeemed {
+				if len(instance.Status.Approvers) != len(c.approval.Status.Approvers) {
+					t.Fatalf("expected no decision to be made, got %+v", instance.Status.Approvers)
+				}
+				return
+			}
+
+			approver := instance.Status.GetEffectiveApprover(c.user)
+			if len(instance.Status.Approvers) != 1 || approver == nil || approver.Decision != c.decision || approver.Reason != c.reason {
+				t.Fatalf("expected %s by %s to be recorded, got %+v", c.decision, c.user, instance.Status.Approvers)
+			}
+			if approver.UserID != c.user || approver.OnBehalfOf != "" || approver.Group != "" {
+				t.Fatalf("expected the user's own decision, got %+v", approver)
+			}
+		})
+	}
+}
+
+func TestDecisionRedeemer_DoubleRedeem(t *testing.T) {
+	s := newTestServer(t, decisionApproval())
+	approve, _ := signToken(t, s, decisionApproval(), "bob", tmaxv1.DecisionApproved)
+
+	if w := redeem(s, approve, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Used token cannot change the decision made afterwards
+	instance := decidedApproval(t, s)
+	instance.Status.Approvers[0].Decision = tmaxv1.DecisionRejected
+	if err := s.client.Status().Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if w := redeem(s, approve, ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if d := decidedApproval(t, s).Status.Approvers[0].Decision; d != tmaxv1.DecisionRejected {
+		t.Fatalf("expected the decision to be kept, got %s", d)
+	}
+}
+
+func TestDecisionConfirmer(t *testing.T) {
+	approval := decisionApproval()
+	approval.Spec.RequireRejectionReason = true
+	s := newTestServer(t, approval)
+
+	reject, _ := signToken(t, s, approval, "bob", tmaxv1.DecisionRejected)
+	w := serve(s, "GET", "/decide?token="+url.QueryEscape(reject), "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if body := w.Body.String(); !strings.Contains(body, `name="reason" required`) {
+		t.Fatalf("expected the reason to be asked, got %s", body)
+	}
+
+	// Confirmation page does not make the decision
+	if approvers := decidedApproval(t, s).Status.Approvers; len(approvers) != 0 {
+		t.Fatalf("expected no decision to be made, got %+v", approvers)
+	}
+
+	if w := serve(s, "GET", "/decide?token=invalid", "", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d: %s", http.StatusUnauthorized, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	DefaultAddr     = ":8081"
+	ShutdownTimeout = 10 * time.Second
+)
+
+var log = logf.Log.WithName("approval-server")
+
+// Addr returns the address the approval server listens on
+func Addr() string {
+	addr := os.Getenv("APPROVAL_SERVER_ADDR")
+	if addr == "" {
+		return DefaultAddr
+	}
+	return addr
+}
+
+// Server serves the http api for the tasks to create and manage approvals
+type Server struct {
+	addr  string
+	cache cache.Cache
+	ready int32
+
+	// client is the manager's client, reading objects from the cache
+	client client.Client
+	// directClient reads objects from the api server, for the objects which might not be in the cache yet
+	directClient client.Client
+}
+
+// blank assignment to verify that Server implements manager.Runnable
+var _ manager.Runnable = &Server{}
+var _ manager.LeaderElectionRunnable = &Server{}
+
+func New(mgr manager.Manager, addr string) *Server {
+	return &Server{
+		addr:   addr,
+		cache:  mgr.GetCache(),
+		client: mgr.GetClient(),
+		directClient: &client.DelegatingClient{
+			Reader:       mgr.GetAPIReader(),
+			Writer:       mgr.GetClient(),
+			StatusClient: mgr.GetClient(),
+		},
+	}
+}
+
+func (s *Server) router() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/approval", s.approvalCreator).Methods("POST")
+	router.HandleFunc("/approval/{namespace}/{name}", s.approvalGetter).Methods("GET")
+	router.HandleFunc("/approval/{namespace}/{name}", s.approvalDeleter).Methods("DELETE")
+
+	router.HandleFunc("/decide", s.decisionConfirmer).Methods("GET")
+	router.HandleFunc("/decide", s.decisionRedeemer).Methods("POST")
+
+	router.HandleFunc("/healthz", s.healthz).Methods("GET")
+	router.HandleFunc("/readyz", s.readyz).Methods("GET")
+	return router
+}
+
+// Start serves the api until the stop channel is closed, then shuts down the server gracefully
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv := &http.Server{
+		Addr:    s.addr,
+		Handler: s.router(),
+	}
+
+	// Ready when the cache is synced
+	go func() {
+		if s.cache.WaitForCacheSync(stop) {
+			atomic.StoreInt32(&s.ready, 1)
+		}
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Info("Starting approval server", "addr", s.addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-stop:
+	}
+
+	log.Info("Shutting down approval server")
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// NeedLeaderElection makes only the leader serve the api, as the controller does
+func (s *Server) NeedLeaderElection() bool {
+	return true
+}
+
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) readyz(w http.ResponseWriter, _ *http.Request) {
+	if atomic.LoadInt32(&s.ready) == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
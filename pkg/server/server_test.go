@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// syncCache is synced when the channel is closed
+type syncCache struct {
+	cache.Cache
+	synced chan struct{}
+}
+
+func (c *syncCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	select {
+	case <-c.synced:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// freeAddr returns a local address no one listens on
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// waitStatus polls the path until it responds with the code
+func waitStatus(t *testing.T, url string, code int) {
+	var got int
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get(url)
+		if err == nil {
+			got = resp.StatusCode
+			resp.Body.Close()
+			if got == code {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected %s to respond %d, got %d", url, code, got)
+}
+
+func TestServer_Start(t *testing.T) {
+	addr := freeAddr(t)
+	c := &syncCache{synced: make(chan struct{})}
+	s := &Server{addr: addr, cache: c}
+
+	stop := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start(stop)
+	}()
+
+	// Alive but not ready until the cache is synced
+	waitStatus(t, "http://"+addr+"/healthz", http.StatusOK)
+	waitStatus(t, "http://"+addr+"/readyz", http.StatusServiceUnavailable)
+
+	close(c.synced)
+	waitStatus(t, "http://"+addr+"/readyz", http.StatusOK)
+
+	// Shut down gracefully when stopped
+	close(stop)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("expected the server to shut down without error, got %v", err)
+		}
+	case <-time.After(ShutdownTimeout):
+		t.Fatal("server is not shut down")
+	}
+	if _, err := http.Get("http://" + addr + "/healthz"); err == nil {
+		t.Fatal("expected the server not to serve after shut down")
+	}
+}
+
+func TestServer_StartListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := &Server{addr: l.Addr().String(), cache: &syncCache{synced: make(chan struct{})}}
+	stop := make(chan struct{})
+	defer close(stop)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start(stop)
+	}()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("expected an error as the address is in use")
+		}
+	case <-time.After(ShutdownTimeout):
+		t.Fatal("server does not return the listen error")
+	}
+}
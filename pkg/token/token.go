@@ -0,0 +1,135 @@
+package token
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"approval-operator/internal"
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+const (
+	// SecretName is the secret in the operator namespace holding the key the tokens are signed with
+	SecretName = "approval-decision-token"
+	KeyField   = "key"
+	KeyLength  = 32
+
+	// DefaultTTL is the lifetime of a token, if the approval does not expire before
+	DefaultTTL = 72 * time.Hour
+)
+
+// Claims are the capability a token grants: the user makes the decision on the approval, until it expires
+type Claims struct {
+	// ID identifies the token, to make it single-use
+	ID        string              `json:"jti"`
+	UID       types.UID           `json:"uid"`
+	Namespace string              `json:"ns"`
+	Name      string              `json:"name"`
+	User      string              `json:"user"`
+	Decision  tmaxv1.DecisionType `json:"decision"`
+	ExpiresAt int64               `json:"exp"`
+}
+
+// NewClaims returns the claims for the user to make the decision on the approval.
+// It expires after DefaultTTL or when the approval expires, whichever comes first
+func NewClaims(approval *tmaxv1.Approval, user string, decision tmaxv1.DecisionType, now time.Time) (*Claims, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	exp := now.Add(DefaultTTL)
+	if expiry := approval.ExpiryTime(); expiry != nil && expiry.Before(exp) {
+		exp = *expiry
+	}
+
+	return &Claims{
+		ID:        hex.EncodeToString(id),
+		UID:       approval.UID,
+		Namespace: approval.Namespace,
+		Name:      approval.Name,
+		User:      user,
+		Decision:  decision,
+		ExpiresAt: exp.Unix(),
+	}, nil
+}
+
+// Sign returns the token of the claims, in the form of <base64 claims>.<base64 HMAC-SHA256 of the claims>
+func Sign(key []byte, claims *Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac(key, encoded)), nil
+}
+
+// Parse verifies the token and returns its claims. Expired tokens are rejected
+func Parse(key []byte, token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("malformed token")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	if !hmac.Equal(sig, mac(key, parts[0])) {
+		return nil, errors.New("token signature mismatch")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %s", err.Error())
+	}
+
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("token expired at %s", time.Unix(claims.ExpiresAt, 0).Format(time.RFC3339))
+	}
+	return claims, nil
+}
+
+func mac(key []byte, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// EnsureKey returns the key the tokens are signed with, creating its secret in the operator namespace if it does not exist
+func EnsureKey(ctx context.Context, c client.Client) ([]byte, error) {
+	ns, err := internal.Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	key := types.NamespacedName{Name: SecretName, Namespace: ns}
+	secret, err := internal.EnsureSecret(ctx, c, key, func() (*corev1.Secret, error) {
+		signingKey := make([]byte, KeyLength)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, err
+		}
+		return &corev1.Secret{Data: map[string][]byte{KeyField: signingKey}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data[KeyField], nil
+}
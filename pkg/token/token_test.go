@@ -0,0 +1,57 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func TestParse(t *testing.T) {
+	key := []byte("test-key")
+	now := time.Now()
+	approval := &tmaxv1.Approval{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"}}
+
+	claims, err := NewClaims(approval, "alice", tmaxv1.DecisionApproved, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := Sign(key, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Parse(key, token, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *claims {
+		t.Fatalf("expected %+v, got %+v", claims, got)
+	}
+
+	if _, err := Parse([]byte("other-key"), token, now); err == nil {
+		t.Fatal("expected error for the token signed with another key")
+	}
+	if _, err := Parse(key, token, now.Add(DefaultTTL)); err == nil {
+		t.Fatal("expected error for the expired token")
+	}
+	if _, err := Parse(key, "a"+token, now); err == nil {
+		t.Fatal("expected error for the tampered token")
+	}
+}
+
+func TestNewClaims_Expiry(t *testing.T) {
+	now := time.Now()
+	deadline := metav1.NewTime(now.Add(time.Hour))
+	approval := &tmaxv1.Approval{Spec: tmaxv1.ApprovalSpec{Deadline: &deadline}}
+
+	claims, err := NewClaims(approval, "alice", tmaxv1.DecisionRejected, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ExpiresAt != deadline.Unix() {
+		t.Fatalf("expected token to expire with the approval at %d, got %d", deadline.Unix(), claims.ExpiresAt)
+	}
+}
@@ -0,0 +1,35 @@
+package approval
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+// delegators returns the users in the policy who delegated their decisions to the delegate, valid at the time
+func delegators(ctx context.Context, c client.Client, policy *tmaxv1.ApproverPolicy, delegate string, t time.Time) (map[string]bool, error) {
+	result := map[string]bool{}
+	if len(policy.Users) == 0 {
+		return result, nil
+	}
+
+	delegations := &tmaxv1.ApproverDelegationList{}
+	if err := c.List(ctx, delegations); err != nil {
+		return nil, fmt.Errorf("cannot list approver delegations, err: %s", err.Error())
+	}
+
+	for _, d := range delegations.Items {
+		if d.Spec.Delegate != delegate || !d.IsValidAt(t) {
+			continue
+		}
+		if _, exist := policy.Users[d.Spec.User]; exist {
+			result[d.Spec.User] = true
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+const (
+	DelegationValidationPath = "/validate-approverdelegations"
+
+	// DelegateVerb is the virtual verb on approverdelegations.tmax.io permitting to delegate the decisions of other users
+	DelegateVerb = "delegate"
+)
+
+// DelegationValidator checks that a user delegates only one's own decisions
+type DelegationValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+func (v *DelegationValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	reqLogger := logf.Log.WithName("webhook-delegation-validating")
+
+	delegation := &tmaxv1.ApproverDelegation{}
+	if err := v.decoder.Decode(req, delegation); err != nil {
+		reqLogger.Error(err, "unable to decode webhook request (object)")
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if err := ValidateDelegation(delegation); err != nil {
+		reqLogger.Info(fmt.Sprintf("spec validation failed, err: %s", err.Error()))
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Delegation taken over from another user is also checked at update
+	users := []string{delegation.Spec.User}
+	if req.Operation == admissionv1beta1.Update {
+		oldDelegation := &tmaxv1.ApproverDelegation{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldDelegation); err != nil {
+			reqLogger.Error(err, "unable to decode webhook request (oldObject)")
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		users = append(users, oldDelegation.Spec.User)
+	}
+
+	for _, user := range users {
+		if err := authorizeDelegation(ctx, v.Client, user, req.UserInfo); err != nil {
+			reqLogger.Info(fmt.Sprintf("authorization failed, err: %s", err.Error()))
+			return admission.Errored(http.StatusForbidden, err)
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+func (v *DelegationValidator) InjectClient(c client.Client) error {
+	v.Client = c
+	return nil
+}
+
+func (v *DelegationValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// ValidateDelegation validates fields' values of the delegation
+func ValidateDelegation(delegation *tmaxv1.ApproverDelegation) error {
+	spec := delegation.Spec
+	if spec.User == "" || spec.Delegate == "" {
+		return errors.New("user and delegate should be specified")
+	}
+	if spec.User == spec.Delegate {
+		return fmt.Errorf("user(%s) cannot delegate the decisions to oneself", spec.User)
+	}
+	if spec.ValidFrom != nil && spec.ValidUntil != nil && !spec.ValidFrom.Before(spec.ValidUntil) {
+		return fmt.Errorf("validFrom(%s) should be before validUntil(%s)", spec.ValidFrom.UTC(), spec.ValidUntil.UTC())
+	}
+	return nil
+}
+
+// authorizeDelegation checks if the requester can delegate the decisions of the user.
+// Users delegate their own decisions. The operator, and the admins permitted the delegate verb by RBAC, can delegate
+// the decisions of any user
+func authorizeDelegation(ctx context.Context, c client.Client, user string, userInfo authenticationv1.UserInfo) error {
+	if userInfo.Username == user {
+		return nil
+	}
+
+	isOperator, err := isUserOperator(userInfo)
+	if err != nil {
+		return err
+	}
+	if isOperator {
+		return nil
+	}
+
+	allowed, err := subjectAccessReview(ctx, c, &authorizationv1.ResourceAttributes{
+		Verb:     DelegateVerb,
+		Group:    tmaxv1.SchemeGroupVersion.Group,
+		Resource: "approverdelegations",
+	}, userInfo)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("user(%s) cannot delegate the decisions of user(%s)", userInfo.Username, user)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
+
+func delegation(user, delegate string) *tmaxv1.ApproverDelegation {
+	return &tmaxv1.ApproverDelegation{
+		TypeMeta:   metav1.TypeMeta{APIVersion: tmaxv1.SchemeGroupVersion.String(), Kind: "ApproverDelegation"},
+		ObjectMeta: metav1.ObjectMeta{Name: user + "-to-" + delegate},
+		Spec:       tmaxv1.ApproverDelegationSpec{User: user, Delegate: delegate},
+	}
+}
+
+func TestValidateDelegation(t *testing.T) {
+	now := metav1.Now()
+	later := metav1.NewTime(now.Add(time.Hour))
+
+	inverted := delegation("alice", "erin")
+	inverted.Spec.ValidFrom, inverted.Spec.ValidUntil = &later, &now
+
+	window := delegation("alice", "erin")
+	window.Spec.ValidFrom, window.Spec.ValidUntil = &now, &later
+
+	tc := map[string]struct {
+		delegation *tmaxv1.ApproverDelegation
+		valid      bool
+	}{
+		"valid":      {delegation: delegation("alice", "erin"), valid: true},
+		"window":     {delegation: window, valid: true},
+		"noDelegate": {delegation: delegation("alice", ""), valid: false},
+		"toOneself":  {delegation: delegation("alice", "alice"), valid: false},
+		"inverted":   {delegation: inverted, valid: false},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateDelegation(c.delegation)
+			if c.valid && err != nil {
+				t.Fatalf("expected to be valid, got error: %s", err.Error())
+			}
+			if !c.valid && err == nil {
+				t.Fatal("expected to be invalid, but valid")
+			}
+		})
+	}
+}
+
+func TestDelegationValidator_Handle(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := tmaxv1.SchemeBuilder.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := func(d *tmaxv1.ApproverDelegation) runtime.RawExtension {
+		b, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runtime.RawExtension{Raw: b}
+	}
+
+	tc := map[string]struct {
+		operation admissionv1beta1.Operation
+		object    *tmaxv1.ApproverDelegation
+		old       *tmaxv1.ApproverDelegation
+		username  string
+		allowed   bool
+	}{
+		"own":      {operation: admissionv1beta1.Create, object: delegation("alice", "erin"), username: "alice", allowed: true},
+		"forged":   {operation: admissionv1beta1.Create, object: delegation("alice", "erin"), username: "erin", allowed: false},
+		"admin":    {operation: admissionv1beta1.Create, object: delegation("alice", "erin"), username: "admin", allowed: true},
+		"operator": {operation: admissionv1beta1.Create, object: delegation("alice", "erin"), username: "system:serviceaccount:default:approval-operator", allowed: true},
+		"invalid":  {operation: admissionv1beta1.Create, object: delegation("alice", "alice"), username: "alice", allowed: false},
+		"updateOwn": {operation: admissionv1beta1.Update, object: delegation("alice", "frank"), old: delegation("alice", "erin"),
+			username: "alice", allowed: true},
+		"takeOver": {operation: admissionv1beta1.Update, object: delegation("erin", "frank"), old: delegation("alice", "frank"),
+			username: "erin", allowed: false},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			v := &DelegationValidator{}
+			if err := v.InjectClient(&reviewClient{Client: newFakeClient(t), allowed: map[string]bool{"admin": true}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := v.InjectDecoder(decoder); err != nil {
+				t.Fatal(err)
+			}
+
+			req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: c.operation,
+				Object:    raw(c.object),
+				UserInfo:  authenticationv1.UserInfo{Username: c.username},
+			}}
+			if c.old != nil {
+				req.OldObject = raw(c.old)
+			}
+
+			resp := v.Handle(context.TODO(), req)
+			if resp.Allowed != c.allowed {
+				t.Fatalf("expected allowed to be %t, got %+v", c.allowed, resp.Result)
+			}
+		})
+	}
+}
@@ -7,10 +7,11 @@ import (
 	"net"
 	"net/http"
 	"reflect"
+	"time"
 
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	authenticationv1 "k8s.io/api/authentication/v1"
-	corev1 "k8s.io/api/core/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -24,7 +25,7 @@ type Validator struct {
 	decoder *admission.Decoder
 }
 
-func (v *Validator) Handle(_ context.Context, req admission.Request) admission.Response {
+func (v *Validator) Handle(ctx context.Context, req admission.Request) admission.Response {
 	reqLogger := logf.Log.WithName("webhook-approval-validating")
 
 	// Requested content
@@ -37,7 +38,7 @@ func (v *Validator) Handle(_ context.Context, req admission.Request) admission.R
 	reqLogger.Info(fmt.Sprintf("USER: %+v", req.UserInfo))
 
 	// Validate contents at create
-	if err := validate(approval); err != nil {
+	if err := Validate(approval); err != nil {
 		reqLogger.Info(fmt.Sprintf("spec validation failed, err: %s", err.Error()))
 		return admission.Errored(http.StatusBadRequest, err)
 	}
@@ -59,19 +60,16 @@ func (v *Validator) Handle(_ context.Context, req admission.Request) admission.R
 			return admission.Errored(http.StatusBadRequest, err)
 		}
 
-		// If update performed after approved/rejected, reject (all fields are immutable after final decision is made)
-		approvedCond := oldApproval.Status.GetCondition(tmaxv1.ConditionApproved)
-		rejectedCond := oldApproval.Status.GetCondition(tmaxv1.ConditionRejected)
-		if (approvedCond != nil && approvedCond.Status == corev1.ConditionTrue) ||
-			(rejectedCond != nil && rejectedCond.Status == corev1.ConditionTrue) {
-			errMsg := "updating after rejected/approved is forbidden"
+		// If update performed after the approving process ended, reject (all fields are immutable after final decision is made)
+		if oldApproval.Status.IsFinal() {
+			errMsg := "updating after the approval ended is forbidden"
 			err := errors.New(errMsg)
 			reqLogger.Info(errMsg)
 			return admission.Errored(http.StatusBadRequest, err)
 		}
 
 		// Authenticate at status change
-		if err := authenticate(approval, oldApproval, req.UserInfo); err != nil {
+		if err := authenticate(ctx, v.Client, approval, oldApproval, req.UserInfo); err != nil {
 			reqLogger.Info(fmt.Sprintf("authorization failed, err: %s", err.Error()))
 			return admission.Errored(http.StatusUnauthorized, err)
 		}
@@ -90,8 +88,8 @@ func (v *Validator) InjectDecoder(d *admission.Decoder) error {
 	return nil
 }
 
-// Validate fields' values
-func validate(approval *tmaxv1.Approval) error {
+// Validate validates fields' values
+func Validate(approval *tmaxv1.Approval) error {
 	// Port number validation
 	if approval.Spec.Port < 1 || approval.Spec.Port > 65535 {
 		return fmt.Errorf("port number(%d) is not in range of 1-65535", approval.Spec.Port)
@@ -107,21 +105,83 @@ func validate(approval *tmaxv1.Approval) error {
 		return fmt.Errorf("podIP(%s) is not valid IP", approval.Spec.PodIP)
 	}
 
-	// Number of users should be greater than 0
-	if len(approval.Spec.Users) < 1 {
-		return fmt.Errorf("there should be one or more users specified")
+	// Either users or stages should be specified
+	if len(approval.Spec.Stages) == 0 {
+		if err := approval.Spec.ApproverPolicy.Validate(); err != nil {
+			return err
+		}
+	} else {
+		if !approval.Spec.ApproverPolicy.IsEmpty() {
+			return fmt.Errorf("approver policy should be specified in each stage, if stages are specified")
+		}
+		names := map[string]bool{}
+		for _, stage := range approval.Spec.Stages {
+			if stage.Name == "" {
+				return fmt.Errorf("name of a stage should not be empty")
+			}
+			if names[stage.Name] {
+				return fmt.Errorf("duplicated stage name(%s)", stage.Name)
+			}
+			names[stage.Name] = true
+
+			if err := stage.ApproverPolicy.Validate(); err != nil {
+				return fmt.Errorf("stage(%s): %s", stage.Name, err.Error())
+			}
+		}
+	}
+
+	// Scheme should be one of http or https
+	if s := approval.Spec.Scheme; s != "" && s != tmaxv1.SchemeHTTP && s != tmaxv1.SchemeHTTPS {
+		return fmt.Errorf("scheme(%s) should be one of %s or %s", s, tmaxv1.SchemeHTTP, tmaxv1.SchemeHTTPS)
+	}
+
+	// Timeout should be positive
+	if approval.Spec.Timeout != nil && approval.Spec.Timeout.Duration <= 0 {
+		return fmt.Errorf("timeout(%s) should be greater than 0", approval.Spec.Timeout.Duration)
+	}
+
+	// Reminder interval should be positive
+	if approval.Spec.ReminderInterval != nil && approval.Spec.ReminderInterval.Duration <= 0 {
+		return fmt.Errorf("reminder interval(%s) should be greater than 0", approval.Spec.ReminderInterval.Duration)
+	}
+
+	// Escalation steps should be in increasing order of duration, and widen the approvers
+	var after time.Duration
+	for i, step := range approval.Spec.Escalations {
+		if step.After.Duration <= after {
+			return fmt.Errorf("duration(%s) of escalation step %d should be greater than %s", step.After.Duration, i+1, after)
+		}
+		after = step.After.Duration
+
+		if len(step.Users) == 0 && len(step.Groups) == 0 {
+			return fmt.Errorf("escalation step %d should specify users or groups", i+1)
+		}
 	}
 
-	// Threshold should be greater or equal to 1, less or equal to len(users)
-	if approval.Spec.Threshold < 1 || int(approval.Spec.Threshold) > len(approval.Spec.Users) {
-		return fmt.Errorf("threshold(%d) should be greater or equal to 1, less or equal to the length of users", approval.Spec.Threshold)
+	// Default decision should be one of Approved or Rejected
+	if d := approval.Spec.DefaultDecision; d != "" && d != tmaxv1.DecisionApproved && d != tmaxv1.DecisionRejected {
+		return fmt.Errorf("default decision(%s) should be one of %s or %s", d, tmaxv1.DecisionApproved, tmaxv1.DecisionRejected)
 	}
 
 	// Validate status field
+	for _, a := range approval.Status.Approvers {
+		switch a.Decision {
+		case tmaxv1.DecisionApproved, tmaxv1.DecisionRejected, tmaxv1.DecisionAbstain, tmaxv1.DecisionRequestChanges:
+		default:
+			return fmt.Errorf("decision(%s) of user(%s) should be one of %s, %s, %s or %s", a.Decision, a.UserID,
+				tmaxv1.DecisionApproved, tmaxv1.DecisionRejected, tmaxv1.DecisionAbstain, tmaxv1.DecisionRequestChanges)
+		}
+
+		// Rejection should be justified, if required
+		if approval.Spec.RequireRejectionReason && a.Decision == tmaxv1.DecisionRejected && a.Reason == "" {
+			return fmt.Errorf("reason is required for the rejection of user(%s)", a.UserID)
+		}
+	}
+	// A user decides once, by oneself or by a delegate
 	for i := range approval.Status.Approvers {
 		for j := i + 1; j < len(approval.Status.Approvers); j++ {
-			if approval.Status.Approvers[i].UserID == approval.Status.Approvers[j].UserID {
-				return fmt.Errorf("duplicated user id(%s) in 'status.approvers' field", approval.Status.Approvers[i].UserID)
+			if approval.Status.Approvers[i].EffectiveUser() == approval.Status.Approvers[j].EffectiveUser() {
+				return fmt.Errorf("duplicated user id(%s) in 'status.approvers' field", approval.Status.Approvers[i].EffectiveUser())
 			}
 		}
 	}
@@ -129,8 +189,17 @@ func validate(approval *tmaxv1.Approval) error {
 	return nil
 }
 
+// Authenticate checks if the user is permitted the change of the status, as the webhook does at status update.
+// It is for the changes the operator makes for the user, e.g., redeeming a decision token
+func Authenticate(ctx context.Context, c client.Client, approval *tmaxv1.Approval, oldApproval *tmaxv1.Approval, userInfo authenticationv1.UserInfo) error {
+	if oldApproval.Status.IsFinal() {
+		return errors.New("updating after the approval ended is forbidden")
+	}
+	return authenticate(ctx, c, approval, oldApproval, userInfo)
+}
+
 // Authenticate if the user requested the change is permitted to change specific field
-func authenticate(approval *tmaxv1.Approval, oldApproval *tmaxv1.Approval, userInfo authenticationv1.UserInfo) error {
+func authenticate(ctx context.Context, c client.Client, approval *tmaxv1.Approval, oldApproval *tmaxv1.Approval, userInfo authenticationv1.UserInfo) error {
 	status := approval.Status
 	oldStatus := oldApproval.Status
 
@@ -144,9 +213,32 @@ func authenticate(approval *tmaxv1.Approval, oldApproval *tmaxv1.Approval, userI
 		return nil
 	}
 
-	// Changes to status field is permitted only for operator and the users specified in spec.users
-	_, exist := approval.Spec.Users[userInfo.Username]
-	if !exist {
+	// Decisions are not accepted after the deadline, even before the operator marks it expired
+	if expiry := approval.ExpiryTime(); expiry != nil && time.Now().After(*expiry) {
+		return fmt.Errorf("approval is expired at %s", expiry.Format(time.RFC3339))
+	}
+
+	// Changes to status field is permitted only for operator and the users/group members specified in the stage in progress
+	policy := approval.ActivePolicy()
+	_, isUser := policy.Users[userInfo.Username]
+	groups := memberGroups(policy, userInfo)
+
+	// Users not listed are reviewed by RBAC, if access review is used
+	if !isUser && policy.AccessReview != nil {
+		allowed, err := reviewAccess(ctx, c, approval, policy.AccessReview, userInfo)
+		if err != nil {
+			return err
+		}
+		isUser = allowed
+	}
+
+	// Users delegated their decisions to this user
+	delegators, err := delegators(ctx, c, policy, userInfo.Username, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !isUser && len(groups) == 0 && len(delegators) == 0 {
 		return fmt.Errorf("user(%s) is not requested for the approval", userInfo.Username)
 	}
 
@@ -160,6 +252,13 @@ func authenticate(approval *tmaxv1.Approval, oldApproval *tmaxv1.Approval, userI
 		return fmt.Errorf("only operator can update 'retry' filed")
 	}
 
+	// Changed any other field except 'approvers' --> permit only if user is operator
+	others, oldOthers := status.DeepCopy(), oldStatus.DeepCopy()
+	others.Approvers, oldOthers.Approvers = nil, nil
+	if !reflect.DeepEqual(others, oldOthers) {
+		return fmt.Errorf("only operator can update status fields other than 'approvers'")
+	}
+
 	// Changed 'approvers' field --> permit only if the user modified his/her field (if is operator, just permit)
 	if !reflect.DeepEqual(status.Approvers, oldStatus.Approvers) {
 		// Find updated approver
@@ -168,12 +267,83 @@ func authenticate(approval *tmaxv1.Approval, oldApproval *tmaxv1.Approval, userI
 			if a.UserID != userInfo.Username {
 				return fmt.Errorf("changing other user's(%s) status field by a user(%s) is forbidden", a.UserID, userInfo.Username)
 			}
+			if old := oldStatus.GetEffectiveApprover(a.EffectiveUser()); old != nil && old.UserID != userInfo.Username {
+				return fmt.Errorf("changing other user's(%s) status field by a user(%s) is forbidden", old.UserID, userInfo.Username)
+			}
+
+			// Deleted one doesn't need to be checked
+			if status.GetEffectiveApprover(a.EffectiveUser()) == nil {
+				continue
+			}
+
+			// The decision on behalf of another user should be delegated to the user
+			if a.OnBehalfOf != "" {
+				if !delegators[a.OnBehalfOf] || a.Group != "" {
+					return fmt.Errorf("user(%s) is not delegated the decision of user(%s)", userInfo.Username, a.OnBehalfOf)
+				}
+				continue
+			}
+
+			// The decision should be counted through a group the user belongs to, if the user is not in users
+			if a.Group == "" && !isUser {
+				return fmt.Errorf("user(%s) is not in users, group should be specified", userInfo.Username)
+			}
+			if a.Group != "" && !groups[a.Group] {
+				return fmt.Errorf("user(%s) is not a member of requested group(%s)", userInfo.Username, a.Group)
+			}
 		}
 	}
 
 	return nil
 }
 
+// memberGroups returns the groups of the policy the user belongs to
+func memberGroups(policy *tmaxv1.ApproverPolicy, userInfo authenticationv1.UserInfo) map[string]bool {
+	groups := map[string]bool{}
+	for _, g := range userInfo.Groups {
+		if policy.GetGroup(g) != nil {
+			groups[g] = true
+		}
+	}
+	return groups
+}
+
+// reviewAccess issues a SubjectAccessReview to check if the user is permitted the verb on the approval
+func reviewAccess(ctx context.Context, c client.Client, approval *tmaxv1.Approval, review *tmaxv1.AccessReview, userInfo authenticationv1.UserInfo) (bool, error) {
+	attributes := &authorizationv1.ResourceAttributes{
+		Namespace: approval.Namespace,
+		Verb:      review.GetVerb(),
+		Group:     tmaxv1.SchemeGroupVersion.Group,
+		Resource:  "approvals",
+	}
+	if review.ScopeToName {
+		attributes.Name = approval.Name
+	}
+	return subjectAccessReview(ctx, c, attributes, userInfo)
+}
+
+// subjectAccessReview issues a SubjectAccessReview to check if the user is permitted the access to the resource
+func subjectAccessReview(ctx context.Context, c client.Client, attributes *authorizationv1.ResourceAttributes, userInfo authenticationv1.UserInfo) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range userInfo.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               userInfo.Username,
+			Groups:             userInfo.Groups,
+			UID:                userInfo.UID,
+			Extra:              extra,
+		},
+	}
+	if err := c.Create(ctx, sar); err != nil {
+		return false, fmt.Errorf("cannot review access of user(%s), err: %s", userInfo.Username, err.Error())
+	}
+	return sar.Status.Allowed, nil
+}
+
 func isUserOperator(userInfo authenticationv1.UserInfo) (bool, error) {
 	ns, err := internal.Namespace()
 	if err != nil {
@@ -189,7 +359,7 @@ func difference(approvers []tmaxv1.Approver, oldApprovers []tmaxv1.Approver) []t
 	for _, a1 := range approvers {
 		found := false
 		for _, a2 := range oldApprovers {
-			if a1.UserID == a2.UserID {
+			if a1.EffectiveUser() == a2.EffectiveUser() {
 				found = true
 				// Check if changed
 				if !reflect.DeepEqual(a1, a2) {
@@ -207,7 +377,7 @@ func difference(approvers []tmaxv1.Approver, oldApprovers []tmaxv1.Approver) []t
 	for _, a2 := range oldApprovers {
 		found := false
 		for _, a1 := range approvers {
-			if a2.UserID == a1.UserID {
+			if a2.EffectiveUser() == a1.EffectiveUser() {
 				found = true
 				break
 			}
@@ -1,7 +1,208 @@
 package approval
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tmaxv1 "approval-operator/pkg/apis/tmax/v1"
+)
 
 func TestValidator_Handle(t *testing.T) {
 	// TODO
 }
+
+// reviewClient answers SubjectAccessReviews with the allowed users
+type reviewClient struct {
+	client.Client
+	allowed map[string]bool
+	reviews []authorizationv1.SubjectAccessReview
+}
+
+func (c *reviewClient) Create(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+	sar := obj.(*authorizationv1.SubjectAccessReview)
+	sar.Status.Allowed = c.allowed[sar.Spec.User]
+	c.reviews = append(c.reviews, *sar)
+	return nil
+}
+
+// newFakeClient returns a fake client aware of the types of tmax.io
+func newFakeClient(t *testing.T, objs ...runtime.Object) client.Client {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmaxv1.SchemeBuilder.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewFakeClientWithScheme(s, objs...)
+}
+
+func TestAuthenticate(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour))
+	delegations := []runtime.Object{
+		&tmaxv1.ApproverDelegation{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice-to-erin"},
+			Spec:       tmaxv1.ApproverDelegationSpec{User: "alice", Delegate: "erin"},
+		},
+		&tmaxv1.ApproverDelegation{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice-to-frank"},
+			Spec:       tmaxv1.ApproverDelegationSpec{User: "alice", Delegate: "frank", ValidUntil: &past},
+		},
+	}
+
+	oldApproval := &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: tmaxv1.ApprovalSpec{
+			ApproverPolicy: tmaxv1.ApproverPolicy{
+				Threshold: 1,
+				Users:     map[string]string{"alice": "alice@tmax.co.kr"},
+				Groups:    []tmaxv1.GroupApprover{{Name: "sre", Threshold: 2}},
+			},
+		},
+	}
+
+	vote := func(a tmaxv1.Approver, review *tmaxv1.AccessReview) *tmaxv1.Approval {
+		approval := oldApproval.DeepCopy()
+		approval.Spec.AccessReview = review
+		approval.Status.Approvers = []tmaxv1.Approver{a}
+		return approval
+	}
+
+	tc := map[string]struct {
+		approval *tmaxv1.Approval
+		userInfo authenticationv1.UserInfo
+		allowed  bool
+	}{
+		"user": {
+			approval: vote(tmaxv1.Approver{UserID: "alice", Decision: tmaxv1.DecisionApproved}, nil),
+			userInfo: authenticationv1.UserInfo{Username: "alice"},
+			allowed:  true,
+		},
+		"otherUser": {
+			approval: vote(tmaxv1.Approver{UserID: "alice", Decision: tmaxv1.DecisionApproved}, nil),
+			userInfo: authenticationv1.UserInfo{Username: "bob", Groups: []string{"sre"}},
+			allowed:  false,
+		},
+		"groupMember": {
+			approval: vote(tmaxv1.Approver{UserID: "bob", Decision: tmaxv1.DecisionApproved, Group: "sre"}, nil),
+			userInfo: authenticationv1.UserInfo{Username: "bob", Groups: []string{"sre"}},
+			allowed:  true,
+		},
+		"groupMemberWithoutGroup": {
+			approval: vote(tmaxv1.Approver{UserID: "bob", Decision: tmaxv1.DecisionApproved}, nil),
+			userInfo: authenticationv1.UserInfo{Username: "bob", Groups: []string{"sre"}},
+			allowed:  false,
+		},
+		"notGroupMember": {
+			approval: vote(tmaxv1.Approver{UserID: "bob", Decision: tmaxv1.DecisionApproved, Group: "sre"}, nil),
+			userInfo: authenticationv1.UserInfo{Username: "bob", Groups: []string{"dev"}},
+			allowed:  false,
+		},
+		"accessReviewAllowed": {
+			approval: vote(tmaxv1.Approver{UserID: "carol", Decision: tmaxv1.DecisionApproved}, &tmaxv1.AccessReview{}),
+			userInfo: authenticationv1.UserInfo{Username: "carol"},
+			allowed:  true,
+		},
+		"delegate": {
+			approval: vote(tmaxv1.Approver{UserID: "erin", Decision: tmaxv1.DecisionApproved, OnBehalfOf: "alice"}, nil),
+			userInfo: authenticationv1.UserInfo{Username: "erin"},
+			allowed:  true,
+		},
+		"delegateExpired": {
+			approval: vote(tmaxv1.Approver{UserID: "frank", Decision: tmaxv1.DecisionApproved, OnBehalfOf: "alice"}, nil),
+			userInfo: authenticationv1.UserInfo{Username: "frank"},
+			allowed:  false,
+		},
+		"delegateOfOtherUser": {
+			approval: vote(tmaxv1.Approver{UserID: "erin", Decision: tmaxv1.DecisionApproved, OnBehalfOf: "bob"}, nil),
+			userInfo: authenticationv1.UserInfo{Username: "erin"},
+			allowed:  false,
+		},
+		"accessReviewDenied": {
+			approval: vote(tmaxv1.Approver{UserID: "dave", Decision: tmaxv1.DecisionApproved}, &tmaxv1.AccessReview{}),
+			userInfo: authenticationv1.UserInfo{Username: "dave"},
+			allowed:  false,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			cli := &reviewClient{Client: newFakeClient(t, delegations...), allowed: map[string]bool{"carol": true}}
+			err := authenticate(context.TODO(), cli, c.approval, oldApproval, c.userInfo)
+			if c.allowed && err != nil {
+				t.Fatalf("expected to be allowed, got error: %s", err.Error())
+			}
+			if !c.allowed && err == nil {
+				t.Fatal("expected to be forbidden, but allowed")
+			}
+		})
+	}
+}
+
+func TestAuthenticateAccessReviewAttributes(t *testing.T) {
+	oldApproval := &tmaxv1.Approval{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: tmaxv1.ApprovalSpec{
+			ApproverPolicy: tmaxv1.ApproverPolicy{
+				Threshold:    1,
+				AccessReview: &tmaxv1.AccessReview{ScopeToName: true},
+			},
+		},
+	}
+	approval := oldApproval.DeepCopy()
+	approval.Status.Approvers = []tmaxv1.Approver{{UserID: "carol", Decision: tmaxv1.DecisionApproved}}
+
+	cli := &reviewClient{Client: newFakeClient(t), allowed: map[string]bool{"carol": true}}
+	if err := authenticate(context.TODO(), cli, approval, oldApproval, authenticationv1.UserInfo{Username: "carol"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cli.reviews) != 1 {
+		t.Fatalf("expected 1 review, got %d", len(cli.reviews))
+	}
+	attr := cli.reviews[0].Spec.ResourceAttributes
+	if attr.Verb != tmaxv1.DefaultAccessReviewVerb || attr.Group != "tmax.io" || attr.Resource != "approvals" || attr.Namespace != "default" || attr.Name != "test" {
+		t.Fatalf("unexpected resource attributes: %+v", attr)
+	}
+}
+
+func TestValidate_RejectionReason(t *testing.T) {
+	tc := map[string]struct {
+		required bool
+		reason   string
+		valid    bool
+	}{
+		"notRequired":   {required: false, reason: "", valid: true},
+		"withReason":    {required: true, reason: "the image is not scanned", valid: true},
+		"withoutReason": {required: true, reason: "", valid: false},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			approval := &tmaxv1.Approval{
+				Spec: tmaxv1.ApprovalSpec{
+					PodIP:                  "10.0.0.1",
+					Port:                   10203,
+					AccessPath:             "/",
+					ApproverPolicy:         tmaxv1.ApproverPolicy{Threshold: 1, Users: map[string]string{"alice": "alice@tmax.co.kr"}},
+					RequireRejectionReason: c.required,
+				},
+				Status: tmaxv1.ApprovalStatus{
+					Approvers: []tmaxv1.Approver{{UserID: "alice", Decision: tmaxv1.DecisionRejected, Reason: c.reason}},
+				},
+			}
+			if err := Validate(approval); (err == nil) != c.valid {
+				t.Fatalf("expected valid to be %t, got %v", c.valid, err)
+			}
+		})
+	}
+}